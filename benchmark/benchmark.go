@@ -1,10 +1,30 @@
 package benchmark
 
+// Tree is the API shared by orderstat, google/btree and petar/GoLLRB which is
+// exercised by the benchmarks.
 type Tree interface {
 	AscendGreaterOrEqual(pivot Item, iterator ItemIterator)
 	AscendLessThan(pivot Item, iterator ItemIterator)
 	AscendRange(greaterOrEqual, lessThan Item, iterator ItemIterator)
+	DescendLessOrEqual(pivot Item, iterator ItemIterator)
 	ReplaceOrInsert(item Item) Item
 	Get(key Item) Item
+	Has(key Item) bool
 	Delete(item Item) Item
+	DeleteMin() Item
+	DeleteMax() Item
+	Min() Item
+	Max() Item
+	Len() int
+}
+
+// RankedTree extends Tree with the order statistic operations provided by
+// orderstat. Backends without native support emulate them with a scan.
+type RankedTree interface {
+	Tree
+	// Rank returns the number of items less than item if item is in the
+	// tree, or -1 otherwise.
+	Rank(item Item) int
+	// Select returns the item with rank i, or nil if i is out of range.
+	Select(i int) Item
 }
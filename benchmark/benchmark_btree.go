@@ -1,3 +1,4 @@
+//go:build btree
 // +build btree
 
 package benchmark
@@ -12,3 +13,35 @@ const Name = "btree"
 func NewTree() Tree {
 	return btree.New(64)
 }
+
+func NewRankedTree() RankedTree {
+	return rankedBTree{btree.New(64)}
+}
+
+// rankedBTree emulates Rank and Select on a btree by scanning in order.
+type rankedBTree struct {
+	*btree.BTree
+}
+
+func (t rankedBTree) Rank(item Item) (rank int) {
+	if !t.Has(item) {
+		return -1
+	}
+	t.AscendLessThan(item, func(Item) bool {
+		rank++
+		return true
+	})
+	return rank
+}
+
+func (t rankedBTree) Select(i int) (selected Item) {
+	t.Ascend(func(item Item) bool {
+		if i == 0 {
+			selected = item
+			return false
+		}
+		i--
+		return true
+	})
+	return selected
+}
@@ -0,0 +1,52 @@
+//go:build llrb
+// +build llrb
+
+package benchmark
+
+import "github.com/petar/GoLLRB/llrb"
+
+type Item = llrb.Item
+type ItemIterator = llrb.ItemIterator
+
+const Name = "llrb"
+
+func NewTree() Tree {
+	return llrb.New()
+}
+
+func NewRankedTree() RankedTree {
+	return rankedLLRB{llrb.New()}
+}
+
+// rankedLLRB emulates Rank and Select on an llrb by scanning in order. The
+// scans do not use AscendLessThan, which visits the wrong items in this
+// version of llrb.
+type rankedLLRB struct {
+	*llrb.LLRB
+}
+
+func (t rankedLLRB) Rank(item Item) (rank int) {
+	if !t.Has(item) {
+		return -1
+	}
+	t.AscendRange(t.Min(), item, func(Item) bool {
+		rank++
+		return true
+	})
+	return rank
+}
+
+func (t rankedLLRB) Select(i int) (selected Item) {
+	if i < 0 || i >= t.Len() {
+		return nil
+	}
+	t.AscendGreaterOrEqual(t.Min(), func(item Item) bool {
+		if i == 0 {
+			selected = item
+			return false
+		}
+		i--
+		return true
+	})
+	return selected
+}
@@ -1,3 +1,4 @@
+//go:build !btree && !llrb
 // +build !btree,!llrb
 
 package benchmark
@@ -12,3 +13,7 @@ const Name = "orderstat"
 func NewTree() Tree {
 	return orderstat.NewTree()
 }
+
+func NewRankedTree() RankedTree {
+	return orderstat.NewTree()
+}
@@ -2,6 +2,7 @@ package benchmark
 
 import (
 	"math/rand"
+	"runtime"
 	"sort"
 	"strconv"
 	"testing"
@@ -21,6 +22,28 @@ func makeData(n int) []Item {
 	return data
 }
 
+func makeSequentialData(n int) []Item {
+	data := make([]Item, n)
+	for i := 0; i < n; i++ {
+		data[i] = Int(i)
+	}
+	return data
+}
+
+func makeTree(data []Item) Tree {
+	t := NewTree()
+	for _, item := range data {
+		t.ReplaceOrInsert(item)
+	}
+	return t
+}
+
+func sortData(data []Item) {
+	sort.Slice(data, func(i, j int) bool {
+		return data[i].(Int) < data[j].(Int)
+	})
+}
+
 func BenchmarkGet(b *testing.B) {
 	b.Run(Name, func(b *testing.B) {
 		t := NewTree()
@@ -75,3 +98,200 @@ func BenchmarkInsert(b *testing.B) {
 		}
 	})
 }
+
+func BenchmarkInsertSequential(b *testing.B) {
+	b.Run(Name, func(b *testing.B) {
+		t := NewTree()
+		data := makeSequentialData(b.N)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			t.ReplaceOrInsert(data[i])
+		}
+	})
+}
+
+func BenchmarkDelete(b *testing.B) {
+	b.Run(Name, func(b *testing.B) {
+		data := makeData(b.N)
+		t := makeTree(data)
+		perm := rand.Perm(b.N)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			t.Delete(data[perm[i]])
+		}
+	})
+}
+
+func BenchmarkDeleteMin(b *testing.B) {
+	b.Run(Name, func(b *testing.B) {
+		t := makeTree(makeData(b.N))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			t.DeleteMin()
+		}
+	})
+}
+
+func BenchmarkDeleteMax(b *testing.B) {
+	b.Run(Name, func(b *testing.B) {
+		t := makeTree(makeData(b.N))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			t.DeleteMax()
+		}
+	})
+}
+
+// BenchmarkMixed performs a random mix of reads and writes against a tree of a
+// fixed size. Writes alternate between inserts of new items and deletes of
+// existing items, so the tree never shrinks below its initial size.
+func BenchmarkMixed(b *testing.B) {
+	const size = 1 << 16
+	b.Run(Name, func(b *testing.B) {
+		for _, readPercent := range []int{50, 90, 99} {
+			b.Run(strconv.Itoa(readPercent), func(b *testing.B) {
+				data := makeData(size + b.N)
+				t := makeTree(data[:size])
+				live := append([]Item(nil), data[:size]...)
+				next, writes := size, 0
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					j := rand.Intn(len(live))
+					if rand.Intn(100) < readPercent {
+						t.Get(live[j])
+						continue
+					}
+					writes++
+					if writes%2 == 1 {
+						t.ReplaceOrInsert(data[next])
+						live = append(live, data[next])
+						next++
+					} else {
+						t.Delete(live[j])
+						live[j] = live[len(live)-1]
+						live = live[:len(live)-1]
+					}
+				}
+			})
+		}
+	})
+}
+
+func BenchmarkDescendLessOrEqual(b *testing.B) {
+	b.Run(Name, func(b *testing.B) {
+		for _, l := range []int{1, 10, 100, 1000} {
+			b.Run(strconv.Itoa(l), func(b *testing.B) {
+				N := b.N + l
+				data := makeData(N)
+				t := makeTree(data)
+				sortData(data)
+				starts := make([]int, b.N)
+				for i := range starts {
+					starts[i] = l + rand.Intn(N-l)
+				}
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					n := 0
+					t.DescendLessOrEqual(data[starts[i]], func(item Item) bool {
+						n++
+						return n < l
+					})
+				}
+			})
+		}
+	})
+}
+
+// BenchmarkMemoryAfterChurn reports the heap retained by a tree of a fixed
+// size after b.N random delete and insert operations.
+func BenchmarkMemoryAfterChurn(b *testing.B) {
+	b.Run(Name, func(b *testing.B) {
+		for _, size := range []int{1 << 10, 1 << 16} {
+			b.Run(strconv.Itoa(size), func(b *testing.B) {
+				data := makeData(size + b.N)
+				live := append([]Item(nil), data[:size]...)
+				var before, after runtime.MemStats
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+				t := makeTree(live)
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					j := rand.Intn(len(live))
+					t.Delete(live[j])
+					live[j] = data[size+i]
+					t.ReplaceOrInsert(live[j])
+				}
+				b.StopTimer()
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+				retained := float64(after.HeapAlloc) - float64(before.HeapAlloc)
+				b.ReportMetric(retained/float64(t.Len()), "heap-bytes/item")
+				runtime.KeepAlive(t)
+				runtime.KeepAlive(data)
+				runtime.KeepAlive(live)
+			})
+		}
+	})
+}
+
+var rankedSizes = []int{1 << 10, 1 << 13, 1 << 16}
+
+func makeRankedTree(data []Item) RankedTree {
+	t := NewRankedTree()
+	for _, item := range data {
+		t.ReplaceOrInsert(item)
+	}
+	return t
+}
+
+func BenchmarkRank(b *testing.B) {
+	b.Run(Name, func(b *testing.B) {
+		for _, size := range rankedSizes {
+			b.Run(strconv.Itoa(size), func(b *testing.B) {
+				data := makeData(size)
+				t := makeRankedTree(data)
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					t.Rank(data[rand.Intn(size)])
+				}
+			})
+		}
+	})
+}
+
+func BenchmarkSelect(b *testing.B) {
+	b.Run(Name, func(b *testing.B) {
+		for _, size := range rankedSizes {
+			b.Run(strconv.Itoa(size), func(b *testing.B) {
+				t := makeRankedTree(makeData(size))
+				size := t.Len()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					t.Select(rand.Intn(size))
+				}
+			})
+		}
+	})
+}
+
+// TestRankedTree checks that the Rank and Select emulations agree with the
+// order of the items.
+func TestRankedTree(t *testing.T) {
+	data := makeData(1000)
+	tr := makeRankedTree(data)
+	sortData(data)
+	for i, item := range data {
+		if got := tr.Rank(item); got != i {
+			t.Fatalf("Rank(%v) = %d, expected %d", item, got, i)
+		}
+		if got := tr.Select(i); got != item {
+			t.Fatalf("Select(%d) = %v, expected %v", i, got, item)
+		}
+	}
+	if got := tr.Rank(Int(-1)); got != -1 {
+		t.Fatalf("Rank of missing item = %d, expected -1", got)
+	}
+	if got := tr.Select(len(data)); got != nil {
+		t.Fatalf("Select(%d) = %v, expected nil", len(data), got)
+	}
+}
@@ -0,0 +1,86 @@
+package orderstat
+
+////////////////////////////////////////////////////////////////////////////////
+// Bulk building
+////////////////////////////////////////////////////////////////////////////////
+
+// build replaces the contents of the empty tree t with items, which must
+// already be in order, in O(n) time.
+//
+// The tree is built as the 2-3 tree of minimal height holding len(items)
+// items, where each 3-node is represented by a black node with a red left
+// child, which is a valid left-leaning red-black tree.
+func (t *Tree) build(items []Item) {
+	if t.root.node != nil {
+		panic("orderstat: build into non-empty tree")
+	}
+	if len(t.list) < len(items) {
+		// Size the arena for every item at once rather than doubling it.
+		t.list, t.fp = nil, iterator{np: null}
+		t.resize(len(items))
+	}
+	// Allocate every node up front so that growing the arena does not
+	// invalidate the iterators held while linking them.
+	nodes := make([]pointer, len(items))
+	for i, item := range items {
+		nodes[i] = t.alloc(item).np
+	}
+	height := 0
+	for minSize(height+1) <= len(items) {
+		height++
+	}
+	t.root = t.buildSubtree(nodes, height)
+	t.root.setIsRed(false)
+}
+
+// buildSubtree links nodes into a subtree of the given black height, which
+// must be able to hold exactly len(nodes) items, and returns its root.
+func (t *Tree) buildSubtree(nodes []pointer, height int) iterator {
+	if len(nodes) == 0 {
+		return iterator{np: null}
+	}
+	// Prefer a 2-node, splitting the remaining items evenly between its two
+	// children, and otherwise use a 3-node with three children.
+	var it iterator
+	if rest := len(nodes) - 1; rest <= 2*maxSize(height-1) {
+		l := rest / 2
+		it.init(t, nodes[l])
+		it.setLeft(t.buildSubtree(nodes[:l], height-1))
+		it.setRight(t.buildSubtree(nodes[l+1:], height-1))
+	} else {
+		rest--
+		a, b := rest/3, rest/3
+		if rest%3 == 2 {
+			b++
+		}
+		var red iterator
+		red.init(t, nodes[a])
+		red.setLeft(t.buildSubtree(nodes[:a], height-1))
+		red.setRight(t.buildSubtree(nodes[a+1:a+1+b], height-1))
+		red.setCount(red.l(t).count() + red.r(t).count() + 1)
+		it.init(t, nodes[a+1+b])
+		it.setLeft(red)
+		it.setRight(t.buildSubtree(nodes[a+2+b:], height-1))
+		red.setIsRed(true)
+	}
+	it.setIsRed(false)
+	it.node.p = null
+	it.setCount(it.l(t).count() + it.r(t).count() + 1)
+	return it
+}
+
+// minSize returns the number of items in the smallest 2-3 tree of the given
+// height, which is made only of 2-nodes.
+func minSize(height int) int {
+	return 1<<height - 1
+}
+
+// maxSize returns the number of items in the largest 2-3 tree of the given
+// height, which is made only of 3-nodes.
+func maxSize(height int) int {
+	n := 1
+	for ; height > 0; height-- {
+		n *= 3
+	}
+	return n - 1
+}
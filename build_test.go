@@ -0,0 +1,32 @@
+package orderstat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuild(t *testing.T) {
+	for n := 0; n < 300; n++ {
+		items := make([]Item, n)
+		for i := range items {
+			items[i] = intItem(2 * i)
+		}
+		tr := NewTree()
+		tr.build(items)
+		require.Nil(t, tr.Validate(), "%d", n)
+		require.Equal(t, n, tr.Len())
+		for i := range items {
+			assert.Equal(t, i, tr.Rank(intItem(2*i)))
+		}
+		// The tree remains usable after building.
+		tr.ReplaceOrInsert(intItem(-1))
+		tr.ReplaceOrInsert(intItem(2*n + 1))
+		tr.Delete(intItem(n / 2 * 2))
+		require.Nil(t, tr.Validate(), "%d", n)
+	}
+	tr := NewTree()
+	tr.ReplaceOrInsert(intItem(1))
+	assert.Panics(t, func() { tr.build(intItems(2)) })
+}
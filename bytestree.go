@@ -0,0 +1,500 @@
+package orderstat
+
+import "bytes"
+
+////////////////////////////////////////////////////////////////////////////////
+// BytesTree
+////////////////////////////////////////////////////////////////////////////////
+
+// BytesTree is an order statistic tree specialized for []byte keys.
+//
+// Unlike Tree, which stores Items and compares them through an interface, a
+// BytesTree copies its keys into a single contiguous byte arena kept
+// alongside its node array and compares them directly against the arena.
+// This avoids an interface value and a separate allocation per key.
+//
+// Keys are prefix compressed. A key which shares at least half of its bytes
+// with its in-order predecessor or successor at the time it is inserted
+// stores only the length of the shared prefix, which refers to the bytes of
+// that neighbour in the arena, and the remaining suffix. Other keys are stored
+// in full so that the keys inserted around them can share their bytes. Sorted
+// keys with long common prefixes, such as paths or zero padded numbers,
+// typically occupy a few bytes each.
+//
+// Keys passed to a BytesTree are copied, so callers may reuse them. Keys
+// returned by a BytesTree must not be modified, but they remain valid after
+// later mutations of the tree. Keys stored in full are returned as slices of
+// the arena, while prefix compressed keys are reassembled into a new slice.
+// The arena is compacted, and its keys compressed relative to their
+// predecessors, once more than half of it is occupied by deleted keys.
+//
+// The iterator passed to Ascend, AscendRange and AscendPrefix must not modify
+// the tree.
+type BytesTree struct {
+	root  pointer
+	fp    pointer
+	nodes []bnode
+	keys  []byte
+
+	// garbage is the number of bytes in keys belonging to deleted keys.
+	garbage int
+}
+
+// bnode is a node of a BytesTree. Its key is keys[poff:poff+pn], the prefix
+// it shares with another key, followed by keys[off:off+n].
+type bnode struct {
+	poff, pn uint32
+	off, n   uint32
+	l, r     pointer
+	c        uint32
+}
+
+// NewBytesTree creates a new, empty BytesTree.
+func NewBytesTree() *BytesTree {
+	return &BytesTree{root: null, fp: null}
+}
+
+// Len returns the number of keys in the tree.
+func (t *BytesTree) Len() int {
+	return int(t.count(t.root))
+}
+
+// Insert adds a copy of key to the tree. It returns false if the key was
+// already present.
+func (t *BytesTree) Insert(key []byte) (added bool) {
+	t.root, added = t.insert(t.root, key, null, null)
+	t.nodes[t.root].c &= countMask
+	return added
+}
+
+// Delete removes key from the tree. It returns false if the key was not
+// present.
+func (t *BytesTree) Delete(key []byte) (deleted bool) {
+	if !t.Has(key) {
+		return false
+	}
+	if r := &t.nodes[t.root]; !t.isRed(r.l) && !t.isRed(r.r) {
+		r.c |= redMask
+	}
+	if t.root = t.del(t.root, key); t.root != null {
+		t.nodes[t.root].c &= countMask
+	}
+	if t.garbage > minCompactBytes && t.garbage > len(t.keys)/2 {
+		t.compact()
+	}
+	return true
+}
+
+// Has returns true if the tree contains key.
+func (t *BytesTree) Has(key []byte) bool {
+	return t.Rank(key) >= 0
+}
+
+// Rank returns the number of keys in the tree which are less than key if key
+// exists in the tree, or -1 otherwise.
+func (t *BytesTree) Rank(key []byte) int {
+	var rank uint32
+	for h := t.root; h != null; {
+		n := &t.nodes[h]
+		switch c := t.compare(key, h); {
+		case c < 0:
+			h = n.l
+		case c > 0:
+			rank += t.count(n.l) + 1
+			h = n.r
+		default:
+			return int(rank + t.count(n.l))
+		}
+	}
+	return -1
+}
+
+// Select returns the key with rank i, that is the i-th smallest key in the
+// tree. It returns nil if i is out of range.
+func (t *BytesTree) Select(i int) []byte {
+	if i < 0 || i >= t.Len() {
+		return nil
+	}
+	rank := uint32(i)
+	for h := t.root; ; {
+		n := &t.nodes[h]
+		lc := t.count(n.l)
+		switch {
+		case rank < lc:
+			h = n.l
+		case rank > lc:
+			rank -= lc + 1
+			h = n.r
+		default:
+			return t.key(h)
+		}
+	}
+}
+
+// Min returns the smallest key in the tree, or nil if the tree is empty.
+func (t *BytesTree) Min() []byte {
+	return t.Select(0)
+}
+
+// Max returns the largest key in the tree, or nil if the tree is empty.
+func (t *BytesTree) Max() []byte {
+	return t.Select(t.Len() - 1)
+}
+
+// Ascend calls the iterator for every key in the tree in ascending order,
+// until iterator returns false.
+func (t *BytesTree) Ascend(f func(key []byte) bool) {
+	t.ascend(t.root, nil, nil, f)
+}
+
+// AscendRange calls the iterator for every key in the tree within the range
+// [greaterOrEqual, lessThan), until iterator returns false. A nil lessThan
+// leaves the range unbounded above.
+func (t *BytesTree) AscendRange(greaterOrEqual, lessThan []byte, f func(key []byte) bool) {
+	t.ascend(t.root, greaterOrEqual, lessThan, f)
+}
+
+// AscendPrefix calls the iterator for every key in the tree which begins with
+// prefix in ascending order, until iterator returns false.
+func (t *BytesTree) AscendPrefix(prefix []byte, f func(key []byte) bool) {
+	t.ascend(t.root, prefix, prefixEnd(prefix), f)
+}
+
+// CountPrefix returns the number of keys in the tree which begin with prefix.
+// It runs in O(log n) time using the subtree counts rather than visiting the
+// matching keys.
+func (t *BytesTree) CountPrefix(prefix []byte) int {
+	end := t.Len()
+	if limit := prefixEnd(prefix); limit != nil {
+		end = t.countLess(limit)
+	}
+	return end - t.countLess(prefix)
+}
+
+// countLess returns the number of keys in the tree which are less than key.
+func (t *BytesTree) countLess(key []byte) int {
+	var rank uint32
+	for h := t.root; h != null; {
+		n := &t.nodes[h]
+		if t.compare(key, h) > 0 {
+			rank += t.count(n.l) + 1
+			h = n.r
+		} else {
+			h = n.l
+		}
+	}
+	return int(rank)
+}
+
+func (t *BytesTree) ascend(h pointer, ge, lt []byte, f func([]byte) bool) bool {
+	if h == null {
+		return true
+	}
+	n := t.nodes[h]
+	aboveLower := ge == nil || t.compare(ge, h) <= 0
+	belowUpper := lt == nil || t.compare(lt, h) > 0
+	if aboveLower && !t.ascend(n.l, ge, lt, f) {
+		return false
+	}
+	if aboveLower && belowUpper && !f(t.key(h)) {
+		return false
+	}
+	return !belowUpper || t.ascend(n.r, ge, lt, f)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// BytesTree memory management
+////////////////////////////////////////////////////////////////////////////////
+
+// minCompactBytes is the amount of garbage below which the key arena is never
+// compacted.
+const minCompactBytes = 4 << 10
+
+// key returns the key stored at h. The capacity of the returned slice is
+// limited so that appending to it cannot overwrite the arena.
+func (t *BytesTree) key(h pointer) []byte {
+	n := &t.nodes[h]
+	if n.pn == 0 {
+		return t.keys[n.off : n.off+n.n : n.off+n.n]
+	}
+	key := make([]byte, 0, n.pn+n.n)
+	key = append(key, t.keys[n.poff:n.poff+n.pn]...)
+	return append(key, t.keys[n.off:n.off+n.n]...)
+}
+
+// compare compares key with the key stored at h without reassembling it.
+func (t *BytesTree) compare(key []byte, h pointer) int {
+	n := &t.nodes[h]
+	prefix := t.keys[n.poff : n.poff+n.pn]
+	if len(key) < len(prefix) {
+		if c := bytes.Compare(key, prefix[:len(key)]); c != 0 {
+			return c
+		}
+		return -1
+	}
+	if c := bytes.Compare(key[:len(prefix)], prefix); c != 0 {
+		return c
+	}
+	return bytes.Compare(key[len(prefix):], t.keys[n.off:n.off+n.n])
+}
+
+// shared returns the location in the arena of the longest prefix of key which
+// can be shared with the key stored at h, or a zero length if h is null or the
+// prefix is too short to be worth sharing.
+func (t *BytesTree) shared(h pointer, key []byte) (poff, pn uint32) {
+	if h == null {
+		return 0, 0
+	}
+	// Only one of the pieces of the neighbour's key can be shared, so a key
+	// sharing a prefix with a compressed neighbour shares at most that prefix.
+	n := &t.nodes[h]
+	if n.pn == 0 {
+		poff, pn = n.off, commonPrefix(key, t.keys[n.off:n.off+n.n])
+	} else {
+		poff, pn = n.poff, commonPrefix(key, t.keys[n.poff:n.poff+n.pn])
+	}
+	if pn < uint32(len(key)+1)/2 {
+		return 0, 0
+	}
+	return poff, pn
+}
+
+func commonPrefix(a, b []byte) uint32 {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return uint32(i)
+		}
+	}
+	return uint32(n)
+}
+
+// alloc allocates a node for key, sharing a prefix with the key stored at
+// either of its neighbours pred and succ, which may be null.
+func (t *BytesTree) alloc(key []byte, pred, succ pointer) pointer {
+	poff, pn := t.shared(pred, key)
+	if soff, sn := t.shared(succ, key); sn > pn {
+		poff, pn = soff, sn
+	}
+	if t.fp == null {
+		t.grow()
+	}
+	h := t.fp
+	t.fp = t.nodes[h].r
+	t.nodes[h] = bnode{
+		poff: poff,
+		pn:   pn,
+		off:  uint32(len(t.keys)),
+		n:    uint32(len(key)) - pn,
+		l:    null,
+		r:    null,
+		c:    redMask | 1,
+	}
+	t.keys = append(t.keys, key[pn:]...)
+	return h
+}
+
+func (t *BytesTree) free(h pointer) {
+	t.nodes[h] = bnode{l: null, r: t.fp}
+	t.fp = h
+}
+
+func (t *BytesTree) grow() {
+	prevLen := len(t.nodes)
+	newLen := 2 * prevLen
+	if prevLen == 0 {
+		const defaultSize = 16
+		newLen = defaultSize
+	}
+	nodes := make([]bnode, newLen)
+	copy(nodes, t.nodes)
+	for i := prevLen; i < newLen; i++ {
+		nodes[i] = bnode{l: null, r: pointer(i + 1)}
+	}
+	nodes[newLen-1].r = null
+	t.nodes = nodes
+	t.fp = pointer(prevLen)
+}
+
+// compact copies the live keys into a new arena in key order, sharing the
+// prefix of each key with the last key stored in full before it. The old
+// arena is left untouched so that previously returned keys stay valid.
+func (t *BytesTree) compact() {
+	keys := make([]byte, 0, len(t.keys)-t.garbage)
+	var base, key []byte
+	var baseOff uint32
+	var walk func(h pointer)
+	walk = func(h pointer) {
+		if h == null {
+			return
+		}
+		walk(t.nodes[h].l)
+		n := &t.nodes[h]
+		key = append(key[:0], t.keys[n.poff:n.poff+n.pn]...)
+		key = append(key, t.keys[n.off:n.off+n.n]...)
+		n.poff, n.pn = baseOff, commonPrefix(key, base)
+		if n.pn < uint32(len(key)+1)/2 {
+			n.poff, n.pn = 0, 0
+		}
+		n.off, n.n = uint32(len(keys)), uint32(len(key))-n.pn
+		keys = append(keys, key[n.pn:]...)
+		if n.pn == 0 {
+			baseOff, base = n.off, keys[n.off:]
+		}
+		walk(n.r)
+	}
+	walk(t.root)
+	t.keys, t.garbage = keys, 0
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// BytesTree balancing
+////////////////////////////////////////////////////////////////////////////////
+
+// The methods below operate on node indices rather than *bnode because
+// inserting may grow the node array.
+
+func (t *BytesTree) count(h pointer) uint32 {
+	if h == null {
+		return 0
+	}
+	return t.nodes[h].c & countMask
+}
+
+func (t *BytesTree) isRed(h pointer) bool {
+	return h != null && t.nodes[h].c&redMask != 0
+}
+
+// insert adds key to the subtree rooted at h. pred and succ are the nearest
+// ancestors of h after and before which key is ordered.
+func (t *BytesTree) insert(h pointer, key []byte, pred, succ pointer) (_ pointer, added bool) {
+	if h == null {
+		return t.alloc(key, pred, succ), true
+	}
+	switch c := t.compare(key, h); {
+	case c < 0:
+		var l pointer
+		l, added = t.insert(t.nodes[h].l, key, pred, h)
+		t.nodes[h].l = l
+	case c > 0:
+		var r pointer
+		r, added = t.insert(t.nodes[h].r, key, h, succ)
+		t.nodes[h].r = r
+	default:
+		return h, false
+	}
+	return t.fixUp(h), added
+}
+
+// del removes key from the subtree rooted at h. The key must be present.
+func (t *BytesTree) del(h pointer, key []byte) pointer {
+	if t.compare(key, h) < 0 {
+		if l := t.nodes[h].l; !t.isRed(l) && !t.isRed(t.nodes[l].l) {
+			h = t.moveRedLeft(h)
+		}
+		t.nodes[h].l = t.del(t.nodes[h].l, key)
+		return t.fixUp(h)
+	}
+	if t.isRed(t.nodes[h].l) {
+		h = t.rotateRight(h)
+	}
+	if t.nodes[h].r == null && t.compare(key, h) == 0 {
+		t.garbage += int(t.nodes[h].n)
+		t.free(h)
+		return null
+	}
+	if r := t.nodes[h].r; !t.isRed(r) && !t.isRed(t.nodes[r].l) {
+		h = t.moveRedRight(h)
+	}
+	if t.compare(key, h) == 0 {
+		t.garbage += int(t.nodes[h].n)
+		r, min := t.delMin(t.nodes[h].r)
+		n := &t.nodes[h]
+		n.r, n.poff, n.pn, n.off, n.n = r, min.poff, min.pn, min.off, min.n
+	} else {
+		t.nodes[h].r = t.del(t.nodes[h].r, key)
+	}
+	return t.fixUp(h)
+}
+
+// delMin removes the smallest node from the subtree rooted at h and returns
+// it so that its key may be moved into another node.
+func (t *BytesTree) delMin(h pointer) (_ pointer, min bnode) {
+	if t.nodes[h].l == null {
+		min = t.nodes[h]
+		t.free(h)
+		return null, min
+	}
+	if l := t.nodes[h].l; !t.isRed(l) && !t.isRed(t.nodes[l].l) {
+		h = t.moveRedLeft(h)
+	}
+	var l pointer
+	l, min = t.delMin(t.nodes[h].l)
+	t.nodes[h].l = l
+	return t.fixUp(h), min
+}
+
+func (t *BytesTree) fixUp(h pointer) pointer {
+	if t.isRed(t.nodes[h].r) {
+		h = t.rotateLeft(h)
+	}
+	if l := t.nodes[h].l; t.isRed(l) && t.isRed(t.nodes[l].l) {
+		h = t.rotateRight(h)
+	}
+	n := &t.nodes[h]
+	if t.isRed(n.l) && t.isRed(n.r) {
+		t.colorFlip(h)
+	}
+	n.c = n.c&redMask | (t.count(n.l) + t.count(n.r) + 1)
+	return h
+}
+
+func (t *BytesTree) rotateLeft(h pointer) pointer {
+	n := &t.nodes[h]
+	x := n.r
+	xn := &t.nodes[x]
+	n.r, xn.l = xn.l, h
+	xn.c = n.c
+	n.c = redMask | (t.count(n.l) + t.count(n.r) + 1)
+	return x
+}
+
+func (t *BytesTree) rotateRight(h pointer) pointer {
+	n := &t.nodes[h]
+	x := n.l
+	xn := &t.nodes[x]
+	n.l, xn.r = xn.r, h
+	xn.c = n.c
+	n.c = redMask | (t.count(n.l) + t.count(n.r) + 1)
+	return x
+}
+
+func (t *BytesTree) colorFlip(h pointer) {
+	n := &t.nodes[h]
+	n.c ^= redMask
+	if n.l != null {
+		t.nodes[n.l].c ^= redMask
+	}
+	if n.r != null {
+		t.nodes[n.r].c ^= redMask
+	}
+}
+
+func (t *BytesTree) moveRedLeft(h pointer) pointer {
+	t.colorFlip(h)
+	if r := t.nodes[h].r; t.isRed(t.nodes[r].l) {
+		t.nodes[h].r = t.rotateRight(r)
+		h = t.rotateLeft(h)
+		t.colorFlip(h)
+	}
+	return h
+}
+
+func (t *BytesTree) moveRedRight(h pointer) pointer {
+	t.colorFlip(h)
+	if l := t.nodes[h].l; t.isRed(t.nodes[l].l) {
+		h = t.rotateRight(h)
+		t.colorFlip(h)
+	}
+	return h
+}
@@ -0,0 +1,251 @@
+package orderstat
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (t *BytesTree) validate() error {
+	if t.isRed(t.root) {
+		return fmt.Errorf("red root")
+	}
+	_, err := t.validateNode(t.root, nil, nil)
+	return err
+}
+
+func (t *BytesTree) validateNode(h pointer, min, max []byte) (blackHeight int, err error) {
+	if h == null {
+		return 0, nil
+	}
+	n, key := t.nodes[h], t.key(h)
+	if min != nil && bytes.Compare(key, min) <= 0 {
+		return 0, fmt.Errorf("key %q <= min %q", key, min)
+	}
+	if max != nil && bytes.Compare(key, max) >= 0 {
+		return 0, fmt.Errorf("key %q >= max %q", key, max)
+	}
+	if t.isRed(n.r) {
+		return 0, fmt.Errorf("right leaning red link at %q", key)
+	}
+	if t.isRed(h) && t.isRed(n.l) {
+		return 0, fmt.Errorf("consecutive red links at %q", key)
+	}
+	lh, err := t.validateNode(n.l, min, key)
+	if err != nil {
+		return 0, err
+	}
+	rh, err := t.validateNode(n.r, key, max)
+	if err != nil {
+		return 0, err
+	}
+	if lh != rh {
+		return 0, fmt.Errorf("black height mismatch at %q: %d != %d", key, lh, rh)
+	}
+	if t.count(h) != t.count(n.l)+t.count(n.r)+1 {
+		return 0, fmt.Errorf("count mismatch at %q", key)
+	}
+	if !t.isRed(h) {
+		lh++
+	}
+	return lh, nil
+}
+
+func TestBytesTree(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	tr := NewBytesTree()
+	m := map[string]bool{}
+	randKey := func() []byte {
+		key := make([]byte, rng.Intn(4))
+		for i := range key {
+			key[i] = "ab\xff"[rng.Intn(3)]
+		}
+		return key
+	}
+	for i := 0; i < 20000; i++ {
+		key := randKey()
+		if rng.Intn(3) == 0 {
+			assert.Equal(t, m[string(key)], tr.Delete(key))
+			delete(m, string(key))
+		} else {
+			assert.Equal(t, !m[string(key)], tr.Insert(key))
+			m[string(key)] = true
+		}
+		// The tree must not retain the caller's slice.
+		for i := range key {
+			key[i] = 'z'
+		}
+		require.Nil(t, tr.validate())
+		require.Equal(t, len(m), tr.Len())
+	}
+	var want []string
+	for k := range m {
+		want = append(want, k)
+	}
+	sort.Strings(want)
+	var got []string
+	tr.Ascend(func(key []byte) bool {
+		got = append(got, string(key))
+		return true
+	})
+	assert.Equal(t, want, got)
+	for i, k := range want {
+		assert.Equal(t, i, tr.Rank([]byte(k)))
+		assert.Equal(t, k, string(tr.Select(i)))
+	}
+	assert.Equal(t, want[0], string(tr.Min()))
+	assert.Equal(t, want[len(want)-1], string(tr.Max()))
+	assert.Equal(t, -1, tr.Rank([]byte("zz")))
+	assert.Nil(t, tr.Select(len(want)))
+}
+
+func TestBytesTreePrefix(t *testing.T) {
+	tr := NewBytesTree()
+	keys := []string{
+		"", "a", "a\x00", "ab", "abc", "abd", "ac", "b",
+		"\xff", "\xff\x00", "\xff\xff", "\xff\xff\xff", "a\xff", "a\xff\xff",
+	}
+	for _, k := range keys {
+		tr.Insert([]byte(k))
+	}
+	sort.Strings(keys)
+	for _, prefix := range []string{"", "a", "ab", "abc", "abe", "a\xff", "\xff", "\xff\xff", "c"} {
+		var want, got []string
+		for _, k := range keys {
+			if len(k) >= len(prefix) && k[:len(prefix)] == prefix {
+				want = append(want, k)
+			}
+		}
+		tr.AscendPrefix([]byte(prefix), func(key []byte) bool {
+			got = append(got, string(key))
+			return true
+		})
+		assert.Equal(t, want, got, "%q", prefix)
+		assert.Equal(t, len(want), tr.CountPrefix([]byte(prefix)), "%q", prefix)
+	}
+
+	var got []string
+	tr.AscendPrefix([]byte("a"), func(key []byte) bool {
+		got = append(got, string(key))
+		return len(got) < 2
+	})
+	assert.Equal(t, []string{"a", "a\x00"}, got)
+
+	got = got[:0]
+	tr.AscendRange([]byte("ab"), []byte("b"), func(key []byte) bool {
+		got = append(got, string(key))
+		return true
+	})
+	assert.Equal(t, []string{"ab", "abc", "abd", "ac", "a\xff", "a\xff\xff"}, got)
+}
+
+func TestBytesTreeCompact(t *testing.T) {
+	tr := NewBytesTree()
+	const N = 10000
+	for i := 0; i < N; i++ {
+		tr.Insert([]byte(fmt.Sprintf("key-%05d", i)))
+	}
+	size := len(tr.keys)
+	retained := tr.Select(N - 1)
+	for i := 0; i < N-10; i++ {
+		assert.True(t, tr.Delete([]byte(fmt.Sprintf("key-%05d", i))))
+	}
+	assert.Nil(t, tr.validate())
+	assert.True(t, len(tr.keys) < size/2, "%d >= %d", len(tr.keys), size/2)
+	assert.Equal(t, fmt.Sprintf("key-%05d", N-1), string(retained))
+	assert.Equal(t, 10, tr.Len())
+	assert.Equal(t, 10, tr.CountPrefix([]byte("key-")))
+	assert.Equal(t, fmt.Sprintf("key-%05d", N-10), string(tr.Min()))
+}
+
+func TestBytesTreePrefixCompression(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	const N = 10000
+	var total int
+	keys := make([]string, N)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("/tenant/%02d/table/users/row/%06d", i%7, i)
+		total += len(keys[i])
+	}
+	tr := NewBytesTree()
+	for _, i := range rng.Perm(N) {
+		assert.True(t, tr.Insert([]byte(keys[i])))
+	}
+	require.Nil(t, tr.validate())
+	assert.True(t, len(tr.keys) < total/4, "%d >= %d", len(tr.keys), total/4)
+	sort.Strings(keys)
+	for i, k := range keys {
+		assert.Equal(t, k, string(tr.Select(i)))
+		assert.Equal(t, i, tr.Rank([]byte(k)))
+	}
+
+	// Keys returned before a compaction, including reassembled ones, stay
+	// valid, and the compacted arena is compressed as well.
+	retained := make([][]byte, N)
+	for i := range keys {
+		retained[i] = tr.Select(i)
+	}
+	for i := 0; i < N; i += 2 {
+		assert.True(t, tr.Delete([]byte(keys[i])))
+	}
+	tr.compact()
+	require.Nil(t, tr.validate())
+	assert.True(t, len(tr.keys) < total/8, "%d >= %d", len(tr.keys), total/8)
+	for i, k := range keys {
+		assert.Equal(t, k, string(retained[i]))
+		assert.Equal(t, i%2 == 1, tr.Has([]byte(k)))
+	}
+	assert.Equal(t, N/2, tr.CountPrefix([]byte("/tenant/")))
+}
+
+func BenchmarkBytesInsert(b *testing.B) {
+	keys := benchmarkStrings(1 << 16)
+	b.Run("Tree", func(b *testing.B) {
+		items := make([]Item, len(keys))
+		for i, k := range keys {
+			items[i] = stringComparer(k)
+		}
+		benchmarkInsert(b, items)
+	})
+	b.Run("BytesTree", func(b *testing.B) {
+		bkeys := make([][]byte, len(keys))
+		for i, k := range keys {
+			bkeys[i] = []byte(k)
+		}
+		var tr *BytesTree
+		for i := 0; i < b.N; i++ {
+			if i%len(bkeys) == 0 {
+				tr = NewBytesTree()
+			}
+			tr.Insert(bkeys[i%len(bkeys)])
+		}
+	})
+}
+
+func BenchmarkBytesGet(b *testing.B) {
+	keys := benchmarkStrings(1 << 16)
+	b.Run("Tree", func(b *testing.B) {
+		items := make([]Item, len(keys))
+		for i, k := range keys {
+			items[i] = stringComparer(k)
+		}
+		benchmarkGet(b, items)
+	})
+	b.Run("BytesTree", func(b *testing.B) {
+		tr := NewBytesTree()
+		bkeys := make([][]byte, len(keys))
+		for i, k := range keys {
+			bkeys[i] = []byte(k)
+			tr.Insert(bkeys[i])
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			tr.Has(bkeys[i%len(bkeys)])
+		}
+	})
+}
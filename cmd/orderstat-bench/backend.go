@@ -0,0 +1,212 @@
+package main
+
+import (
+	"sort"
+	"unsafe"
+
+	"github.com/ajwerner/orderstat"
+	"github.com/google/btree"
+	"github.com/petar/GoLLRB/llrb"
+)
+
+// backend is the set of operations a workload may perform against a tree.
+// Each implementation wraps one of the candidate tree packages using its own
+// key type so that backends can be chosen at runtime.
+type backend interface {
+	Insert(k int64)
+	Delete(k int64)
+	Get(k int64) bool
+	// Rank returns the number of keys less than k.
+	Rank(k int64) int
+	// Select returns the key with rank i.
+	Select(i int) (int64, bool)
+	// Range returns the number of keys in [lo, hi).
+	Range(lo, hi int64) int
+	Len() int
+	// Arena returns the number of node slots the tree has allocated,
+	// including any kept for reuse, and their size in bytes. ok is false if
+	// the tree does not expose its node storage.
+	Arena() (nodes, bytes int, ok bool)
+}
+
+var backends = map[string]func() backend{
+	"orderstat": newOrderstatBackend,
+	"btree":     newBtreeBackend,
+	"llrb":      newLLRBBackend,
+}
+
+func backendNames() []string {
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// orderstat
+////////////////////////////////////////////////////////////////////////////////
+
+type orderstatKey int64
+
+func (k orderstatKey) Less(other orderstat.Item) bool { return k < other.(orderstatKey) }
+
+type orderstatBackend struct {
+	t *orderstat.Tree
+}
+
+func newOrderstatBackend() backend {
+	return orderstatBackend{t: orderstat.NewTree()}
+}
+
+func (b orderstatBackend) Insert(k int64)   { b.t.ReplaceOrInsert(orderstatKey(k)) }
+func (b orderstatBackend) Delete(k int64)   { b.t.Delete(orderstatKey(k)) }
+func (b orderstatBackend) Get(k int64) bool { return b.t.Get(orderstatKey(k)) != nil }
+func (b orderstatBackend) Len() int         { return b.t.Len() }
+
+func (b orderstatBackend) Arena() (nodes, bytes int, ok bool) {
+	s := b.t.Stats()
+	return s.Capacity, s.EstimatedBytes, true
+}
+
+func (b orderstatBackend) Rank(k int64) int {
+	if _, rank := b.t.Ceiling(orderstatKey(k)); rank >= 0 {
+		return rank
+	}
+	return b.t.Len()
+}
+
+func (b orderstatBackend) Select(i int) (int64, bool) {
+	if item := b.t.Select(i); item != nil {
+		return int64(item.(orderstatKey)), true
+	}
+	return 0, false
+}
+
+func (b orderstatBackend) Range(lo, hi int64) (n int) {
+	b.t.AscendRange(orderstatKey(lo), orderstatKey(hi), func(orderstat.Item) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// btree
+////////////////////////////////////////////////////////////////////////////////
+
+type btreeKey int64
+
+func (k btreeKey) Less(other btree.Item) bool { return k < other.(btreeKey) }
+
+type btreeBackend struct {
+	t *btree.BTree
+}
+
+func newBtreeBackend() backend {
+	return btreeBackend{t: btree.New(64)}
+}
+
+func (b btreeBackend) Insert(k int64)   { b.t.ReplaceOrInsert(btreeKey(k)) }
+func (b btreeBackend) Delete(k int64)   { b.t.Delete(btreeKey(k)) }
+func (b btreeBackend) Get(k int64) bool { return b.t.Get(btreeKey(k)) != nil }
+func (b btreeBackend) Len() int         { return b.t.Len() }
+
+// Arena is not available as btree does not expose its nodes.
+func (b btreeBackend) Arena() (nodes, bytes int, ok bool) { return 0, 0, false }
+
+// Rank has no native support in btree so it is emulated with a scan.
+func (b btreeBackend) Rank(k int64) (n int) {
+	b.t.AscendLessThan(btreeKey(k), func(btree.Item) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+// Select has no native support in btree so it is emulated with a scan.
+func (b btreeBackend) Select(i int) (k int64, ok bool) {
+	b.t.Ascend(func(item btree.Item) bool {
+		if i == 0 {
+			k, ok = int64(item.(btreeKey)), true
+			return false
+		}
+		i--
+		return true
+	})
+	return k, ok
+}
+
+func (b btreeBackend) Range(lo, hi int64) (n int) {
+	b.t.AscendRange(btreeKey(lo), btreeKey(hi), func(btree.Item) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// llrb
+////////////////////////////////////////////////////////////////////////////////
+
+type llrbKey int64
+
+func (k llrbKey) Less(other llrb.Item) bool { return k < other.(llrbKey) }
+
+type llrbBackend struct {
+	t *llrb.LLRB
+}
+
+func newLLRBBackend() backend {
+	return llrbBackend{t: llrb.New()}
+}
+
+func (b llrbBackend) Insert(k int64)   { b.t.ReplaceOrInsert(llrbKey(k)) }
+func (b llrbBackend) Delete(k int64)   { b.t.Delete(llrbKey(k)) }
+func (b llrbBackend) Get(k int64) bool { return b.t.Get(llrbKey(k)) != nil }
+func (b llrbBackend) Len() int         { return b.t.Len() }
+
+// Arena reports one node per item, as llrb allocates a node for each insert
+// and leaves deleted nodes to the garbage collector.
+func (b llrbBackend) Arena() (nodes, bytes int, ok bool) {
+	return b.t.Len(), b.t.Len() * int(unsafe.Sizeof(llrb.Node{})), true
+}
+
+// Rank has no native support in llrb so it is emulated with a scan. The scan
+// does not use AscendLessThan, which visits the wrong items in this version of
+// llrb.
+func (b llrbBackend) Rank(k int64) (n int) {
+	if b.t.Len() == 0 {
+		return 0
+	}
+	b.t.AscendRange(b.t.Min(), llrbKey(k), func(llrb.Item) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+// Select has no native support in llrb so it is emulated with a scan.
+func (b llrbBackend) Select(i int) (k int64, ok bool) {
+	if b.t.Len() == 0 {
+		return 0, false
+	}
+	b.t.AscendGreaterOrEqual(b.t.Min(), func(item llrb.Item) bool {
+		if i == 0 {
+			k, ok = int64(item.(llrbKey)), true
+			return false
+		}
+		i--
+		return true
+	})
+	return k, ok
+}
+
+func (b llrbBackend) Range(lo, hi int64) (n int) {
+	b.t.AscendRange(llrbKey(lo), llrbKey(hi), func(llrb.Item) bool {
+		n++
+		return true
+	})
+	return n
+}
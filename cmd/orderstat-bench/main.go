@@ -0,0 +1,218 @@
+// Command orderstat-bench replays a workload against each of the candidate
+// ordered tree implementations and reports their relative performance.
+//
+// Usage:
+//
+//	orderstat-bench [-backends orderstat,btree,llrb] [-format table|json] workload
+//
+// The workload is either a CSV trace, where each record is op,key[,end], or a
+// JSON description. The JSON description may hold a trace:
+//
+//	{"trace": [{"op": "insert", "key": 1}, {"op": "range", "key": 0, "end": 10}]}
+//
+// or parameters from which a workload is generated:
+//
+//	{
+//	  "seed": 1,
+//	  "preload": 100000,
+//	  "ops": 1000000,
+//	  "keys": 1000000,
+//	  "distribution": "zipf",
+//	  "zipf_s": 1.1,
+//	  "mix": {"insert": 10, "delete": 10, "get": 60, "rank": 10, "select": 5, "range": 5},
+//	  "range_length": 100
+//	}
+//
+// The supported ops are insert, delete, get, rank, select and range, and the
+// supported distributions are uniform, zipf and sequential.
+//
+// The btree and llrb packages have no notion of rank, so rank and select are
+// emulated with a scan for those backends.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func main() {
+	backendsFlag := flag.String("backends", strings.Join(backendNames(), ","),
+		"comma separated list of backends to run")
+	format := flag.String("format", "table", "output format, table or json")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(),
+			"usage: %s [flags] workload\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || (*format != "table" && *format != "json") {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(flag.Arg(0), strings.Split(*backendsFlag, ","), *format, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(path string, names []string, format string, out io.Writer) error {
+	for _, name := range names {
+		if _, ok := backends[name]; !ok {
+			return fmt.Errorf("unknown backend %q, expected one of %s",
+				name, strings.Join(backendNames(), ", "))
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w, err := readWorkload(path, f)
+	if err != nil {
+		return err
+	}
+	results := make([]result, 0, len(names))
+	for _, name := range names {
+		results = append(results, measure(name, backends[name], w))
+	}
+	if format == "json" {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	}
+	return writeTable(out, results)
+}
+
+// result holds the measurements for a single backend.
+type result struct {
+	Backend     string  `json:"backend"`
+	Ops         int     `json:"ops"`
+	NsPerOp     float64 `json:"ns_per_op"`
+	AllocsPerOp float64 `json:"allocs_per_op"`
+	BytesPerOp  float64 `json:"bytes_per_op"`
+	// PeakArenaNodes and PeakArenaBytes are the largest node storage
+	// reported by the backend's Arena method, sampled at regular intervals
+	// during a second, untimed replay. They are nil if the backend does not
+	// expose its node storage.
+	PeakArenaNodes *int `json:"peak_arena_nodes"`
+	PeakArenaBytes *int `json:"peak_arena_bytes"`
+	// PeakProcessHeapBytes is the largest growth of the whole process's heap
+	// over the same samples, taken after garbage collection. Unlike the arena
+	// figures it includes the items themselves and any allocations made by
+	// the harness.
+	PeakProcessHeapBytes uint64 `json:"peak_process_heap_bytes"`
+}
+
+// peakSamples is the number of times the arena and heap are sampled while
+// measuring their peak sizes.
+const peakSamples = 64
+
+// sink prevents the results of read operations from being optimized away.
+var sink int64
+
+func measure(name string, newBackend func() backend, w *workload) result {
+	res := result{Backend: name, Ops: len(w.ops)}
+
+	b := newBackend()
+	for _, k := range w.preload {
+		b.Insert(k)
+	}
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	replay(b, w.ops)
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	if n := float64(len(w.ops)); n > 0 {
+		res.NsPerOp = float64(elapsed.Nanoseconds()) / n
+		res.AllocsPerOp = float64(after.Mallocs-before.Mallocs) / n
+		res.BytesPerOp = float64(after.TotalAlloc-before.TotalAlloc) / n
+	}
+
+	b = nil
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	sample := func() {
+		if nodes, bytes, ok := b.Arena(); ok {
+			if res.PeakArenaNodes == nil {
+				res.PeakArenaNodes, res.PeakArenaBytes = new(int), new(int)
+			}
+			*res.PeakArenaNodes = max(*res.PeakArenaNodes, nodes)
+			*res.PeakArenaBytes = max(*res.PeakArenaBytes, bytes)
+		}
+		var ms runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&ms)
+		if ms.HeapAlloc > before.HeapAlloc {
+			res.PeakProcessHeapBytes = max(res.PeakProcessHeapBytes, ms.HeapAlloc-before.HeapAlloc)
+		}
+	}
+	b = newBackend()
+	for _, k := range w.preload {
+		b.Insert(k)
+	}
+	sample()
+	step := len(w.ops)/peakSamples + 1
+	for i := 0; i < len(w.ops); i += step {
+		end := i + step
+		if end > len(w.ops) {
+			end = len(w.ops)
+		}
+		replay(b, w.ops[i:end])
+		sample()
+	}
+	runtime.KeepAlive(b)
+	return res
+}
+
+func replay(b backend, ops []op) {
+	for _, o := range ops {
+		switch o.kind {
+		case opInsert:
+			b.Insert(o.key)
+		case opDelete:
+			b.Delete(o.key)
+		case opGet:
+			if b.Get(o.key) {
+				sink++
+			}
+		case opRank:
+			sink += int64(b.Rank(o.key))
+		case opSelect:
+			if n := int64(b.Len()); n > 0 {
+				k, _ := b.Select(int(o.key % n))
+				sink += k
+			}
+		case opRange:
+			sink += int64(b.Range(o.key, o.end))
+		}
+	}
+}
+
+func writeTable(out io.Writer, results []result) error {
+	tw := tabwriter.NewWriter(out, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "backend\tops\tns/op\tallocs/op\tB/op\tpeak arena nodes\tpeak arena B\tpeak process heap B\t")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%d\t%.1f\t%.2f\t%.1f\t%s\t%s\t%d\t\n",
+			r.Backend, r.Ops, r.NsPerOp, r.AllocsPerOp, r.BytesPerOp,
+			optional(r.PeakArenaNodes), optional(r.PeakArenaBytes), r.PeakProcessHeapBytes)
+	}
+	return tw.Flush()
+}
+
+// optional formats v for the table, using "-" for a missing value.
+func optional(v *int) string {
+	if v == nil {
+		return "-"
+	}
+	return strconv.Itoa(*v)
+}
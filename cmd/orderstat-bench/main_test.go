@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadCSV(t *testing.T) {
+	f, err := os.Open("testdata/trace.csv")
+	require.NoError(t, err)
+	defer f.Close()
+	w, err := readWorkload(f.Name(), f)
+	require.NoError(t, err)
+	assert.Equal(t, []op{
+		{kind: opInsert, key: 5},
+		{kind: opInsert, key: 1},
+		{kind: opInsert, key: 9},
+		{kind: opGet, key: 5},
+		{kind: opRank, key: 9},
+		{kind: opSelect, key: 1},
+		{kind: opRange, key: 0, end: 6},
+		{kind: opDelete, key: 5},
+		{kind: opGet, key: 5},
+	}, w.ops)
+
+	for _, bad := range []string{"insert", "bogus,1", "insert,x", "range,1", "get,1,2,3"} {
+		_, err := readWorkload("bad.csv", strings.NewReader(bad))
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	for _, dist := range []string{"uniform", "zipf", "sequential"} {
+		s := spec{
+			Seed:         1,
+			Preload:      10,
+			Ops:          1000,
+			Keys:         100,
+			Distribution: dist,
+			Mix:          map[string]int{"insert": 1, "range": 1},
+		}
+		w, err := s.generate()
+		require.NoError(t, err)
+		assert.Len(t, w.preload, 10)
+		assert.Len(t, w.ops, 1000)
+		for _, o := range w.ops {
+			assert.True(t, o.key >= 0 && o.key < 100, "%v", o)
+			if o.kind == opRange {
+				assert.Equal(t, o.key+100, o.end)
+			} else {
+				assert.Equal(t, opInsert, o.kind)
+			}
+		}
+		again, err := s.generate()
+		require.NoError(t, err)
+		assert.Equal(t, w, again)
+	}
+	for _, bad := range []string{
+		`{"keys": 0}`,
+		`{"keys": 10, "ops": 1}`,
+		`{"keys": 10, "mix": {"bogus": 1}}`,
+		`{"keys": 10, "distribution": "bogus"}`,
+		`{"keys": 10, "unknown": 1}`,
+	} {
+		_, err := readWorkload("bad.json", strings.NewReader(bad))
+		assert.Error(t, err, bad)
+	}
+}
+
+// TestBackendsAgree replays a generated workload against every backend and
+// checks that they return the same results for every operation.
+func TestBackendsAgree(t *testing.T) {
+	f, err := os.Open("testdata/mixed.json")
+	require.NoError(t, err)
+	defer f.Close()
+	w, err := readWorkload(f.Name(), f)
+	require.NoError(t, err)
+	var trees []backend
+	for _, name := range backendNames() {
+		b := backends[name]()
+		for _, k := range w.preload {
+			b.Insert(k)
+		}
+		trees = append(trees, b)
+	}
+	for i, o := range w.ops {
+		var results []int64
+		for _, b := range trees {
+			results = append(results, apply(b, o))
+		}
+		for j := range results {
+			require.Equal(t, results[0], results[j], "op %d %v on %s", i, o, backendNames()[j])
+		}
+	}
+}
+
+func apply(b backend, o op) int64 {
+	switch o.kind {
+	case opGet:
+		if b.Get(o.key) {
+			return 1
+		}
+	case opRank:
+		return int64(b.Rank(o.key))
+	case opSelect:
+		if n := int64(b.Len()); n > 0 {
+			k, _ := b.Select(int(o.key % n))
+			return k
+		}
+	case opRange:
+		return int64(b.Range(o.key, o.end))
+	default:
+		replay(b, []op{o})
+	}
+	return int64(b.Len())
+}
+
+func TestRun(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, run("testdata/trace.csv", backendNames(), "json", &buf))
+	var results []result
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &results))
+	require.Len(t, results, len(backends))
+	for _, r := range results {
+		assert.Equal(t, 9, r.Ops)
+		if r.Backend == "btree" {
+			assert.Nil(t, r.PeakArenaNodes)
+			assert.Nil(t, r.PeakArenaBytes)
+			continue
+		}
+		// The trace holds at most three keys at once.
+		require.NotNil(t, r.PeakArenaNodes, r.Backend)
+		assert.True(t, *r.PeakArenaNodes >= 3, "%s: %d", r.Backend, *r.PeakArenaNodes)
+		assert.True(t, *r.PeakArenaBytes > 0, r.Backend)
+	}
+	buf.Reset()
+	require.NoError(t, run("testdata/trace.csv", []string{"orderstat"}, "table", &buf))
+	assert.Contains(t, buf.String(), "orderstat")
+	assert.Contains(t, buf.String(), "peak arena nodes")
+	assert.Error(t, run("testdata/trace.csv", []string{"bogus"}, "table", &buf))
+}
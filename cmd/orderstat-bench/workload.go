@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type opKind int
+
+const (
+	opInsert opKind = iota
+	opDelete
+	opGet
+	opRank
+	opSelect
+	opRange
+)
+
+var opNames = [...]string{
+	opInsert: "insert",
+	opDelete: "delete",
+	opGet:    "get",
+	opRank:   "rank",
+	opSelect: "select",
+	opRange:  "range",
+}
+
+func (k opKind) String() string { return opNames[k] }
+
+func parseOpKind(s string) (opKind, error) {
+	for k, name := range opNames {
+		if name == s {
+			return opKind(k), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown op %q", s)
+}
+
+// op is a single operation in a workload. For select, key is a rank which is
+// taken modulo the size of the tree when replayed. For range, the op visits
+// the keys in [key, end).
+type op struct {
+	kind     opKind
+	key, end int64
+}
+
+// workload is a sequence of operations preceded by a set of keys which are
+// inserted before measurement begins.
+type workload struct {
+	preload []int64
+	ops     []op
+}
+
+// spec is the JSON description of a workload. If Trace is non-empty it is
+// replayed as is, otherwise Ops operations are generated according to Mix
+// with keys drawn from Distribution over [0, Keys).
+type spec struct {
+	Seed         int64          `json:"seed"`
+	Preload      int            `json:"preload"`
+	Ops          int            `json:"ops"`
+	Keys         int64          `json:"keys"`
+	Distribution string         `json:"distribution"`
+	ZipfS        float64        `json:"zipf_s"`
+	Mix          map[string]int `json:"mix"`
+	RangeLength  int64          `json:"range_length"`
+	Trace        []traceOp      `json:"trace"`
+}
+
+type traceOp struct {
+	Op  string `json:"op"`
+	Key int64  `json:"key"`
+	End int64  `json:"end"`
+}
+
+// readWorkload reads a workload from r. Files ending in .csv are parsed as
+// traces, anything else as a JSON spec.
+func readWorkload(name string, r io.Reader) (*workload, error) {
+	if strings.EqualFold(filepath.Ext(name), ".csv") {
+		return readCSV(r)
+	}
+	var s spec
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&s); err != nil {
+		return nil, fmt.Errorf("decoding %s: %v", name, err)
+	}
+	return s.generate()
+}
+
+// readCSV reads a trace where each record is op,key[,end]. A leading header
+// record whose first field is "op" is skipped.
+func readCSV(r io.Reader) (*workload, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	cr.Comment = '#'
+	var w workload
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return &w, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 && rec[0] == "op" {
+			continue
+		}
+		o, err := parseRecord(rec)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		w.ops = append(w.ops, o)
+	}
+}
+
+func parseRecord(rec []string) (o op, err error) {
+	if len(rec) < 2 || len(rec) > 3 {
+		return o, fmt.Errorf("expected op,key[,end], got %d fields", len(rec))
+	}
+	if o.kind, err = parseOpKind(rec[0]); err != nil {
+		return o, err
+	}
+	if o.key, err = strconv.ParseInt(rec[1], 10, 64); err != nil {
+		return o, err
+	}
+	if len(rec) == 3 {
+		if o.end, err = strconv.ParseInt(rec[2], 10, 64); err != nil {
+			return o, err
+		}
+	}
+	if o.kind == opRange && len(rec) != 3 {
+		return o, fmt.Errorf("range requires an end key")
+	}
+	return o, nil
+}
+
+func (s *spec) generate() (*workload, error) {
+	var w workload
+	if len(s.Trace) > 0 {
+		for i, t := range s.Trace {
+			kind, err := parseOpKind(t.Op)
+			if err != nil {
+				return nil, fmt.Errorf("trace op %d: %v", i, err)
+			}
+			w.ops = append(w.ops, op{kind: kind, key: t.Key, end: t.End})
+		}
+		return &w, nil
+	}
+	if s.Keys <= 0 {
+		return nil, fmt.Errorf("keys must be positive")
+	}
+	if s.RangeLength <= 0 {
+		s.RangeLength = 100
+	}
+	rng := rand.New(rand.NewSource(s.Seed))
+	next, err := s.keyGenerator(rng)
+	if err != nil {
+		return nil, err
+	}
+	for name, weight := range s.Mix {
+		if _, err := parseOpKind(name); err != nil {
+			return nil, fmt.Errorf("mix: %v", err)
+		}
+		if weight < 0 {
+			return nil, fmt.Errorf("mix: negative weight for %s", name)
+		}
+	}
+	// Walk the mix in op order so that generation is deterministic for a
+	// given seed.
+	var weights [len(opNames)]int
+	total := 0
+	for k, name := range opNames {
+		weights[k] = s.Mix[name]
+		total += weights[k]
+	}
+	if total == 0 && s.Ops > 0 {
+		return nil, fmt.Errorf("mix must have a positive weight")
+	}
+	for i := 0; i < s.Preload; i++ {
+		w.preload = append(w.preload, next())
+	}
+	for i := 0; i < s.Ops; i++ {
+		r := rng.Intn(total)
+		k := 0
+		for ; r >= weights[k]; k++ {
+			r -= weights[k]
+		}
+		o := op{kind: opKind(k), key: next()}
+		switch o.kind {
+		case opSelect:
+			o.key = rng.Int63n(s.Keys)
+		case opRange:
+			o.end = o.key + s.RangeLength
+		}
+		w.ops = append(w.ops, o)
+	}
+	return &w, nil
+}
+
+func (s *spec) keyGenerator(rng *rand.Rand) (func() int64, error) {
+	switch s.Distribution {
+	case "", "uniform":
+		return func() int64 { return rng.Int63n(s.Keys) }, nil
+	case "zipf":
+		if s.ZipfS == 0 {
+			s.ZipfS = 1.1
+		}
+		if s.ZipfS <= 1 {
+			return nil, fmt.Errorf("zipf_s must be greater than 1")
+		}
+		z := rand.NewZipf(rng, s.ZipfS, 1, uint64(s.Keys-1))
+		return func() int64 { return int64(z.Uint64()) }, nil
+	case "sequential":
+		var i int64
+		return func() int64 {
+			k := i % s.Keys
+			i++
+			return k
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown distribution %q", s.Distribution)
+	}
+}
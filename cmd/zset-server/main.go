@@ -0,0 +1,51 @@
+// Command zset-server serves the sorted set commands of package zset over the
+// Redis protocol (RESP) so that it can stand in for Redis in integration
+// tests.
+//
+// Usage:
+//
+//	zset-server [-addr localhost:6379]
+//
+// The server only accepts connections on a loopback address. It holds all of
+// its data in memory, supports a single database and performs no
+// authentication. Commands are accepted both as RESP arrays, as sent by
+// client libraries, and inline, as typed into a telnet session.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+
+	"github.com/ajwerner/orderstat/zset"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:6379", "loopback address to listen on")
+	flag.Parse()
+	l, err := listen(*addr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	log.Printf("listening on %s", l.Addr())
+	if err := serve(l, zset.NewDB()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// listen listens on addr, which must resolve to a loopback address.
+func listen(addr string) (net.Listener, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if tcp, ok := l.Addr().(*net.TCPAddr); !ok || !tcp.IP.IsLoopback() {
+		l.Close()
+		return nil, fmt.Errorf("refusing to listen on non-loopback address %s", l.Addr())
+	}
+	return l, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/ajwerner/orderstat/zset"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startServer(t *testing.T) net.Conn {
+	l, err := listen("127.0.0.1:0")
+	require.NoError(t, err)
+	done := make(chan error)
+	go func() { done <- serve(l, zset.NewDB()) }()
+	t.Cleanup(func() {
+		l.Close()
+		assert.NoError(t, <-done)
+	})
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestServer(t *testing.T) {
+	conn := startServer(t)
+	// Pipeline RESP and inline commands in a single write.
+	_, err := io.WriteString(conn, ""+
+		"*6\r\n$4\r\nZADD\r\n$1\r\nz\r\n$1\r\n1\r\n$3\r\none\r\n$3\r\n2.5\r\n$7\r\ntwo two\r\n"+
+		"ZRANGE z 0 -1 WITHSCORES\r\n"+
+		"*3\r\n$6\r\nZSCORE\r\n$1\r\nz\r\n$7\r\nmissing\r\n"+
+		"zrank z one\n"+
+		"\r\n"+
+		"*2\r\n$6\r\nZSCORE\r\n$1\r\nz\r\n"+
+		"PING\r\n"+
+		"QUIT\r\n")
+	require.NoError(t, err)
+	got, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	assert.Equal(t, ""+
+		":2\r\n"+
+		"*4\r\n$3\r\none\r\n$1\r\n1\r\n$7\r\ntwo two\r\n$3\r\n2.5\r\n"+
+		"$-1\r\n"+
+		":0\r\n"+
+		"-ERR wrong number of arguments for 'zscore' command\r\n"+
+		"+PONG\r\n"+
+		"+OK\r\n", string(got))
+}
+
+func TestServerProtocolError(t *testing.T) {
+	for _, input := range []string{
+		"*1\r\n+PING\r\n",
+		"*1\r\n$4\r\nPINGxx",
+		"*x\r\n",
+		"*1\r\n$-1\r\n",
+		"*1\r\n$4\r\nPI",
+	} {
+		conn := startServer(t)
+		_, err := io.WriteString(conn, input)
+		require.NoError(t, err)
+		if tcp, ok := conn.(*net.TCPConn); ok {
+			tcp.CloseWrite()
+		}
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		require.NoError(t, err, "%q", input)
+		assert.True(t, strings.HasPrefix(line, "-ERR Protocol error: "), "%q: %q", input, line)
+	}
+}
+
+func TestListenLoopbackOnly(t *testing.T) {
+	l, err := listen("localhost:0")
+	require.NoError(t, err)
+	l.Close()
+	_, err = listen("0.0.0.0:0")
+	assert.Error(t, err)
+}
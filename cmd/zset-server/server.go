@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/ajwerner/orderstat/zset"
+)
+
+// serve accepts connections on l and executes their commands against db until
+// l is closed.
+func serve(l net.Listener, db *zset.DB) error {
+	for {
+		conn, err := l.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		go func() {
+			if err := handle(conn, db); err != nil {
+				log.Printf("%s: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// handle executes the commands read from conn until the client disconnects
+// or sends QUIT.
+func handle(conn net.Conn, db *zset.DB) error {
+	defer conn.Close()
+	r, w := bufio.NewReader(conn), bufio.NewWriter(conn)
+	for {
+		args, err := readCommand(r)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			// The stream can no longer be parsed, so report the error and
+			// drop the connection as Redis does.
+			writeReply(w, zset.Error("ERR Protocol error: "+err.Error()))
+			return errors.Join(err, w.Flush())
+		}
+		if len(args) == 0 {
+			continue
+		}
+		quit := strings.EqualFold(args[0], "quit")
+		if quit {
+			writeReply(w, zset.SimpleString("OK"))
+		} else {
+			writeReply(w, db.Do(args...))
+		}
+		// Flush once the client has no more pipelined commands buffered.
+		if r.Buffered() == 0 || quit {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+		}
+		if quit {
+			return nil
+		}
+	}
+}
+
+const (
+	maxArgs     = 1 << 20
+	maxBulkSize = 512 << 20
+)
+
+// readCommand reads a command which is either a RESP array of bulk strings or
+// an inline command of space separated words terminated by a newline.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	b, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if b[0] != '*' {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		return strings.Fields(line), nil
+	}
+	n, err := readLength(r, '*', maxArgs)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, 0, max(n, 0))
+	for i := 0; i < n; i++ {
+		size, err := readLength(r, '$', maxBulkSize)
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, errors.New("null bulk string in command")
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if buf[size] != '\r' || buf[size+1] != '\n' {
+			return nil, errors.New("bulk string not terminated by CRLF")
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+// readLength reads a line holding prefix followed by a length no greater than
+// limit. A length of -1 denotes a null value.
+func readLength(r *bufio.Reader, prefix byte, limit int) (int, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	if len(line) == 0 || line[0] != prefix {
+		return 0, fmt.Errorf("expected '%c', got %q", prefix, line)
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < -1 || n > limit {
+		return 0, fmt.Errorf("invalid length %q", line[1:])
+	}
+	return n, nil
+}
+
+// readLine reads a line terminated by LF or CRLF and strips the terminator.
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		if err == io.EOF && line != "" {
+			err = io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r"), nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// writeReply encodes a reply returned by zset.DB.Do.
+func writeReply(w *bufio.Writer, reply any) {
+	switch reply := reply.(type) {
+	case nil:
+		w.WriteString("$-1\r\n")
+	case zset.SimpleString:
+		fmt.Fprintf(w, "+%s\r\n", reply)
+	case zset.Error:
+		fmt.Fprintf(w, "-%s\r\n", reply)
+	case int64:
+		fmt.Fprintf(w, ":%d\r\n", reply)
+	case string:
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(reply), reply)
+	case []any:
+		fmt.Fprintf(w, "*%d\r\n", len(reply))
+		for _, r := range reply {
+			writeReply(w, r)
+		}
+	default:
+		panic(fmt.Sprintf("unexpected reply type %T", reply))
+	}
+}
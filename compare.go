@@ -0,0 +1,57 @@
+package orderstat
+
+////////////////////////////////////////////////////////////////////////////////
+// Comparer
+////////////////////////////////////////////////////////////////////////////////
+
+// Comparer may optionally be implemented by an Item to provide a three-way
+// comparison.
+//
+// Locating an item in the tree using only Less requires two calls at every
+// level, one to check whether the item belongs to the left and another to
+// check whether it belongs to the right. When the items stored in a tree
+// created with NewTree implement Comparer, the tree instead uses a single call
+// to Compare at every level. This is worthwhile for items such as strings
+// where each comparison scans a common prefix.
+type Comparer interface {
+	Item
+
+	// Compare returns a negative number if the current item is less than
+	// the given argument, a positive number if it is greater and zero if the
+	// two are equal. It must be consistent with Less.
+	Compare(other Item) int
+}
+
+func itemLess(a, b Item) bool {
+	return a.Less(b)
+}
+
+// itemCompare compares a and b using Compare if a implements Comparer and
+// using Less otherwise.
+func itemCompare(a, b Item) int {
+	if c, ok := a.(Comparer); ok {
+		return c.Compare(b)
+	}
+	switch {
+	case a.Less(b):
+		return -1
+	case b.Less(a):
+		return 1
+	default:
+		return 0
+	}
+}
+
+// lessCompare adapts less into a three-way comparison.
+func lessCompare(less func(a, b Item) bool) func(a, b Item) int {
+	return func(a, b Item) int {
+		switch {
+		case less(a, b):
+			return -1
+		case less(b, a):
+			return 1
+		default:
+			return 0
+		}
+	}
+}
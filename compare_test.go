@@ -0,0 +1,188 @@
+package orderstat
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type stringItem string
+
+func (s stringItem) Less(other Item) bool {
+	return s < other.(stringItem)
+}
+
+type stringComparer string
+
+func (s stringComparer) Less(other Item) bool {
+	return s < other.(stringComparer)
+}
+
+func (s stringComparer) Compare(other Item) int {
+	return strings.Compare(string(s), string(other.(stringComparer)))
+}
+
+// countingItem counts the calls to Less and Compare made by the tree.
+type countingItem struct {
+	v             int
+	less, compare *int
+}
+
+func (c countingItem) Less(other Item) bool {
+	*c.less++
+	return c.v < other.(countingItem).v
+}
+
+func (c countingItem) Compare(other Item) int {
+	*c.compare++
+	return c.v - other.(countingItem).v
+}
+
+func TestComparer(t *testing.T) {
+	var less, compare int
+	item := func(v int) countingItem {
+		return countingItem{v: v, less: &less, compare: &compare}
+	}
+	tr := NewTree()
+	rng := rand.New(rand.NewSource(0))
+	m := map[int]bool{}
+	for i := 0; i < 10000; i++ {
+		v := rng.Intn(500)
+		if rng.Intn(3) == 0 {
+			assert.Equal(t, m[v], tr.Delete(item(v)) != nil)
+			delete(m, v)
+		} else {
+			assert.Equal(t, m[v], tr.ReplaceOrInsert(item(v)) != nil)
+			m[v] = true
+		}
+		assert.Equal(t, m[v], tr.Has(item(v)))
+	}
+	assert.Equal(t, 0, less)
+	assert.NotZero(t, compare)
+	less = 0
+	assert.Nil(t, tr.Validate())
+	assert.Equal(t, len(m), tr.Len())
+	rank := 0
+	tr.Ascend(func(i Item) bool {
+		assert.Equal(t, rank, tr.Rank(i))
+		rank++
+		return true
+	})
+}
+
+func TestComparerWithLess(t *testing.T) {
+	// A tree created with NewTreeWithLess never uses Compare.
+	var less, compare int
+	tr := NewTreeWithLess(func(a, b Item) bool {
+		return a.(countingItem).v > b.(countingItem).v
+	})
+	for _, v := range rand.Perm(100) {
+		tr.ReplaceOrInsert(countingItem{v: v, less: &less, compare: &compare})
+	}
+	assert.Equal(t, 0, less)
+	assert.Equal(t, 0, compare)
+	assert.Equal(t, 99, tr.Min().(countingItem).v)
+}
+
+// benchmarkStrings returns n distinct keys sharing a long common prefix,
+// which is typical of keys built from a table or path prefix.
+func benchmarkStrings(n int) []string {
+	keys := make([]string, n)
+	for i, v := range rand.Perm(n) {
+		keys[i] = fmt.Sprintf("/table/users/index/primary/%010d", v)
+	}
+	return keys
+}
+
+func BenchmarkStringInsert(b *testing.B) {
+	keys := benchmarkStrings(1 << 16)
+	b.Run("Less", func(b *testing.B) {
+		items := make([]Item, len(keys))
+		for i, k := range keys {
+			items[i] = stringItem(k)
+		}
+		benchmarkInsert(b, items)
+	})
+	b.Run("Compare", func(b *testing.B) {
+		items := make([]Item, len(keys))
+		for i, k := range keys {
+			items[i] = stringComparer(k)
+		}
+		benchmarkInsert(b, items)
+	})
+}
+
+func benchmarkInsert(b *testing.B, items []Item) {
+	var tr *Tree
+	for i := 0; i < b.N; i++ {
+		if i%len(items) == 0 {
+			tr = NewTree()
+		}
+		tr.ReplaceOrInsert(items[i%len(items)])
+	}
+}
+
+func BenchmarkStringGet(b *testing.B) {
+	keys := benchmarkStrings(1 << 16)
+	b.Run("Less", func(b *testing.B) {
+		items := make([]Item, len(keys))
+		for i, k := range keys {
+			items[i] = stringItem(k)
+		}
+		benchmarkGet(b, items)
+	})
+	b.Run("Compare", func(b *testing.B) {
+		items := make([]Item, len(keys))
+		for i, k := range keys {
+			items[i] = stringComparer(k)
+		}
+		benchmarkGet(b, items)
+	})
+}
+
+func benchmarkGet(b *testing.B, items []Item) {
+	tr := NewTree()
+	for _, item := range items {
+		tr.ReplaceOrInsert(item)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tr.Get(items[i%len(items)])
+	}
+}
+
+func BenchmarkStringDelete(b *testing.B) {
+	keys := benchmarkStrings(1 << 16)
+	b.Run("Less", func(b *testing.B) {
+		items := make([]Item, len(keys))
+		for i, k := range keys {
+			items[i] = stringItem(k)
+		}
+		benchmarkDelete(b, items)
+	})
+	b.Run("Compare", func(b *testing.B) {
+		items := make([]Item, len(keys))
+		for i, k := range keys {
+			items[i] = stringComparer(k)
+		}
+		benchmarkDelete(b, items)
+	})
+}
+
+func benchmarkDelete(b *testing.B, items []Item) {
+	tr := NewTree()
+	for _, item := range items {
+		tr.ReplaceOrInsert(item)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		item := items[i%len(items)]
+		tr.Delete(item)
+		b.StopTimer()
+		tr.ReplaceOrInsert(item)
+		b.StartTimer()
+	}
+}
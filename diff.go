@@ -0,0 +1,258 @@
+package orderstat
+
+import (
+	"reflect"
+	"slices"
+	"sync/atomic"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// Clone and Diff
+////////////////////////////////////////////////////////////////////////////////
+
+// Clone returns a copy of the tree. Mutations of either tree are not visible
+// in the other. The items themselves are shared rather than copied.
+//
+// Clone copies the node arena, which takes O(n) time but is a single memory
+// copy. The first call to Clone also starts recording which nodes each later
+// mutation modifies, in both trees, so that Diff between the tree and its
+// clones can skip the subtrees they still share. The observer is not copied.
+//
+// Clone is a read operation: it may be called concurrently with other reads,
+// including other calls to Clone.
+func (t *Tree) Clone() *Tree {
+	tr := t.tracking.Load()
+	if tr == nil {
+		tr = &tracking{clock: new(atomic.Uint64), stamps: make([]uint64, len(t.list))}
+		if !t.tracking.CompareAndSwap(nil, tr) {
+			tr = t.tracking.Load()
+		}
+	}
+	c := &Tree{
+		list: slices.Clone(t.list),
+		less: t.less,
+		cmp:  t.cmp,
+		gen:  t.gen,
+	}
+	c.root.init(c, t.root.np)
+	c.fp.init(c, t.fp.np)
+	c.tracking.Store(&tracking{clock: tr.clock, stamps: slices.Clone(tr.stamps)})
+	return c
+}
+
+// Equaler may optionally be implemented by an Item to determine whether two
+// items which are equal in the tree's order hold the same value. Diff uses it
+// to detect changed items. Items which do not implement Equaler are compared
+// with reflect.DeepEqual.
+type Equaler interface {
+	Item
+
+	// Equal returns true if the item holds the same value as other.
+	Equal(other Item) bool
+}
+
+// DiffKind describes how an item differs between two trees.
+type DiffKind int
+
+const (
+	// DiffAdded indicates an item which is only in the new tree.
+	DiffAdded DiffKind = iota + 1
+	// DiffRemoved indicates an item which is only in the old tree.
+	DiffRemoved
+	// DiffChanged indicates an item which is in both trees but whose value
+	// differs.
+	DiffChanged
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case DiffAdded:
+		return "added"
+	case DiffRemoved:
+		return "removed"
+	case DiffChanged:
+		return "changed"
+	default:
+		return "unknown"
+	}
+}
+
+// DiffEntry describes one difference between two trees.
+type DiffEntry struct {
+	Kind DiffKind
+	// Old is the item in the old tree, or nil if the item was added.
+	Old Item
+	// New is the item in the new tree, or nil if the item was removed.
+	New Item
+	// OldRank is the rank of Old in the old tree, or -1 if the item was
+	// added.
+	OldRank int
+	// NewRank is the rank of New in the new tree, or -1 if the item was
+	// removed.
+	NewRank int
+}
+
+// Diff calls f for every difference between old and new in ascending order of
+// the items, until f returns false. Both trees must use the same order.
+//
+// Diff walks both trees in order. When old and new are clones of one another
+// (see Clone), subtrees which neither has modified since they were cloned are
+// skipped without being visited, so Diff takes time proportional to the
+// number of modifications rather than to the size of the trees.
+func Diff(old, new *Tree, f func(DiffEntry) bool) {
+	ot, nt := old.tracking.Load(), new.tracking.Load()
+	shared := ot != nil && nt != nil && ot.clock == nt.clock
+	o, n := newDiffCursor(old), newDiffCursor(new)
+	for len(o.stack) > 0 || len(n.stack) > 0 {
+		var emit DiffEntry
+		switch {
+		case len(n.stack) == 0:
+			emit = o.removed()
+		case len(o.stack) == 0:
+			emit = n.added()
+		default:
+			of, nf := o.top(), n.top()
+			switch {
+			case of.subtree && nf.subtree && shared && of.it.np == nf.it.np &&
+				ot.stamps[of.it.np] == nt.stamps[nf.it.np]:
+				o.skip()
+				n.skip()
+				continue
+			case of.subtree && nf.subtree:
+				// Expand the larger subtree so that a subtree shared with
+				// the other tree reaches the top of both cursors together.
+				oc, nc := of.it.count(), nf.it.count()
+				if oc >= nc {
+					o.expand()
+				}
+				if nc >= oc {
+					n.expand()
+				}
+				continue
+			case of.subtree:
+				// Only expand the subtree once it is known to hold an item
+				// which is not greater than the node on the other side.
+				if old.cmp(o.min(), nf.it.item) <= 0 {
+					o.expand()
+					continue
+				}
+				emit = n.added()
+			case nf.subtree:
+				if old.cmp(of.it.item, n.min()) >= 0 {
+					n.expand()
+					continue
+				}
+				emit = o.removed()
+			default:
+				switch c := old.cmp(of.it.item, nf.it.item); {
+				case c < 0:
+					emit = o.removed()
+				case c > 0:
+					emit = n.added()
+				default:
+					emit = DiffEntry{
+						Kind: DiffChanged,
+						Old:  of.it.item, OldRank: o.rank,
+						New: nf.it.item, NewRank: n.rank,
+					}
+					o.pop()
+					n.pop()
+					if itemsEqual(emit.Old, emit.New) {
+						continue
+					}
+				}
+			}
+		}
+		if !f(emit) {
+			return
+		}
+	}
+}
+
+func itemsEqual(a, b Item) bool {
+	if e, ok := a.(Equaler); ok {
+		return e.Equal(b)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// diffCursor walks a tree in order. Its stack holds the parts of the tree
+// which remain to be visited, with the next in order on top. Each entry is
+// either a single node or an entire subtree which has not yet been expanded.
+type diffCursor struct {
+	t     *Tree
+	stack []diffFrame
+	// rank is the number of items which have been visited or skipped.
+	rank int
+}
+
+type diffFrame struct {
+	it      iterator
+	subtree bool
+}
+
+func newDiffCursor(t *Tree) *diffCursor {
+	c := &diffCursor{t: t}
+	c.pushSubtree(t.root)
+	return c
+}
+
+func (c *diffCursor) top() diffFrame {
+	return c.stack[len(c.stack)-1]
+}
+
+func (c *diffCursor) pop() diffFrame {
+	f := c.top()
+	c.stack = c.stack[:len(c.stack)-1]
+	c.rank++
+	return f
+}
+
+func (c *diffCursor) pushSubtree(it iterator) {
+	if it.node != nil {
+		c.stack = append(c.stack, diffFrame{it: it, subtree: true})
+	}
+}
+
+// expand replaces the subtree on top of the stack with its root and
+// children.
+func (c *diffCursor) expand() {
+	it := c.top().it
+	c.stack = c.stack[:len(c.stack)-1]
+	c.pushSubtree(it.r(c.t))
+	c.stack = append(c.stack, diffFrame{it: it})
+	c.pushSubtree(it.l(c.t))
+}
+
+// skip discards the subtree on top of the stack without visiting it.
+func (c *diffCursor) skip() {
+	c.rank += int(c.top().it.count())
+	c.stack = c.stack[:len(c.stack)-1]
+}
+
+// min returns the smallest item remaining, which must be in the subtree on
+// top of the stack.
+func (c *diffCursor) min() Item {
+	it, _ := c.top().it.min(c.t)
+	return it.item
+}
+
+// removed expands the top of the stack down to its smallest item and pops it
+// as a removed item.
+func (c *diffCursor) removed() DiffEntry {
+	for c.top().subtree {
+		c.expand()
+	}
+	rank := c.rank
+	return DiffEntry{Kind: DiffRemoved, Old: c.pop().it.item, OldRank: rank, NewRank: -1}
+}
+
+// added expands the top of the stack down to its smallest item and pops it as
+// an added item.
+func (c *diffCursor) added() DiffEntry {
+	for c.top().subtree {
+		c.expand()
+	}
+	rank := c.rank
+	return DiffEntry{Kind: DiffAdded, New: c.pop().it.item, OldRank: -1, NewRank: rank}
+}
@@ -0,0 +1,241 @@
+package orderstat
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// diffModel computes the expected result of Diff from the contents of two
+// trees of keyValue items.
+func diffModel(old, new map[string]string) []DiffEntry {
+	keys := map[string]bool{}
+	for k := range old {
+		keys[k] = true
+	}
+	for k := range new {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	entries := []DiffEntry{}
+	var oldRank, newRank int
+	for _, k := range sorted {
+		ov, inOld := old[k]
+		nv, inNew := new[k]
+		switch {
+		case !inNew:
+			entries = append(entries, DiffEntry{
+				Kind: DiffRemoved, Old: kv(k, ov), OldRank: oldRank, NewRank: -1,
+			})
+		case !inOld:
+			entries = append(entries, DiffEntry{
+				Kind: DiffAdded, New: kv(k, nv), OldRank: -1, NewRank: newRank,
+			})
+		case ov != nv:
+			entries = append(entries, DiffEntry{
+				Kind: DiffChanged, Old: kv(k, ov), New: kv(k, nv),
+				OldRank: oldRank, NewRank: newRank,
+			})
+		}
+		if inOld {
+			oldRank++
+		}
+		if inNew {
+			newRank++
+		}
+	}
+	return entries
+}
+
+func collectDiff(old, new *Tree) []DiffEntry {
+	entries := []DiffEntry{}
+	Diff(old, new, func(e DiffEntry) bool {
+		entries = append(entries, e)
+		return true
+	})
+	return entries
+}
+
+func TestDiff(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	mutate := func(tr *Tree, m map[string]string, n int) {
+		for i := 0; i < n; i++ {
+			k := strconv.Itoa(rng.Intn(300))
+			if rng.Intn(3) == 0 {
+				tr.Delete(kv(k, ""))
+				delete(m, k)
+			} else {
+				v := strconv.Itoa(rng.Intn(3))
+				tr.ReplaceOrInsert(kv(k, v))
+				m[k] = v
+			}
+		}
+	}
+	clone := func(m map[string]string) map[string]string {
+		c := make(map[string]string, len(m))
+		for k, v := range m {
+			c[k] = v
+		}
+		return c
+	}
+	for run := 0; run < 200; run++ {
+		a, am := NewTree(), map[string]string{}
+		mutate(a, am, rng.Intn(400))
+		b, bm := a.Clone(), clone(am)
+		assert.Empty(t, collectDiff(a, b))
+
+		mutate(a, am, rng.Intn(20))
+		mutate(b, bm, rng.Intn(20))
+		c, cm := b.Clone(), clone(bm)
+		mutate(c, cm, rng.Intn(20))
+		require.Nil(t, a.Validate())
+		require.Nil(t, b.Validate())
+		require.Nil(t, c.Validate())
+		assert.Equal(t, diffModel(am, bm), collectDiff(a, b))
+		assert.Equal(t, diffModel(bm, am), collectDiff(b, a))
+		assert.Equal(t, diffModel(am, cm), collectDiff(a, c))
+		assert.Equal(t, diffModel(bm, cm), collectDiff(b, c))
+
+		// Trees which are not clones of one another are compared item by
+		// item.
+		d, dm := NewTree(), map[string]string{}
+		mutate(d, dm, rng.Intn(400))
+		assert.Equal(t, diffModel(am, dm), collectDiff(a, d))
+		assert.Equal(t, diffModel(dm, cm), collectDiff(d, c))
+	}
+}
+
+func TestDiffStop(t *testing.T) {
+	a := treeOf(intItems(1, 2, 3, 4)...)
+	b := a.Clone()
+	b.Delete(intItem(1))
+	b.ReplaceOrInsert(intItem(5))
+	b.ReplaceOrInsert(intItem(6))
+	var got []DiffEntry
+	Diff(a, b, func(e DiffEntry) bool {
+		got = append(got, e)
+		return len(got) < 2
+	})
+	assert.Equal(t, []DiffEntry{
+		{Kind: DiffRemoved, Old: intItem(1), OldRank: 0, NewRank: -1},
+		{Kind: DiffAdded, New: intItem(5), OldRank: -1, NewRank: 3},
+	}, got)
+	assert.Empty(t, collectDiff(NewTree(), NewTree()))
+	assert.Equal(t, "removed", got[0].Kind.String())
+}
+
+// versioned is an item which is keyed by k and implements Equaler by
+// comparing versions, ignoring data. It counts the calls to Equal.
+type versioned struct {
+	k, version int
+	data       []byte
+	calls      *int
+}
+
+func (v versioned) Less(other Item) bool {
+	return v.k < other.(versioned).k
+}
+
+func (v versioned) Equal(other Item) bool {
+	*v.calls++
+	return v.version == other.(versioned).version
+}
+
+func TestDiffEqualer(t *testing.T) {
+	var calls int
+	a := NewTree()
+	const N = 10000
+	for i := 0; i < N; i++ {
+		a.ReplaceOrInsert(versioned{k: i, calls: &calls})
+	}
+	b := a.Clone()
+	// An item whose version is unchanged is not reported even though its
+	// data differs.
+	b.ReplaceOrInsert(versioned{k: 10, data: []byte("x"), calls: &calls})
+	b.ReplaceOrInsert(versioned{k: 20, version: 1, calls: &calls})
+	b.Delete(versioned{k: 30})
+	b.ReplaceOrInsert(versioned{k: N, calls: &calls})
+	got := collectDiff(a, b)
+	require.Len(t, got, 3)
+	assert.Equal(t, DiffChanged, got[0].Kind)
+	assert.Equal(t, 20, got[0].New.(versioned).k)
+	assert.Equal(t, 20, got[0].OldRank)
+	assert.Equal(t, 20, got[0].NewRank)
+	assert.Equal(t, DiffRemoved, got[1].Kind)
+	assert.Equal(t, 30, got[1].OldRank)
+	assert.Equal(t, DiffAdded, got[2].Kind)
+	assert.Equal(t, N-1, got[2].NewRank)
+	// Subtrees shared with the clone are skipped, so only the items along
+	// the modified paths are compared.
+	assert.True(t, calls < 200, "%d Equal calls", calls)
+}
+
+func BenchmarkDiff(b *testing.B) {
+	const N = 1 << 16
+	rng := rand.New(rand.NewSource(0))
+	a := NewTree()
+	for i := 0; i < N; i++ {
+		a.ReplaceOrInsert(intItem(rng.Intn(N * 4)))
+	}
+	for _, changes := range []int{1, 100, 10000} {
+		c := a.Clone()
+		for i := 0; i < changes; i++ {
+			c.ReplaceOrInsert(intItem(rng.Intn(N * 4)))
+		}
+		b.Run(strconv.Itoa(changes), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				Diff(a, c, func(DiffEntry) bool { return true })
+			}
+		})
+	}
+	b.Run("unshared", func(b *testing.B) {
+		c := NewTree()
+		a.Ascend(func(item Item) bool {
+			c.ReplaceOrInsert(item)
+			return true
+		})
+		for i := 0; i < b.N; i++ {
+			Diff(a, c, func(DiffEntry) bool { return true })
+		}
+	})
+}
+
+// TestCloneConcurrent clones a tree from several goroutines at once, which is
+// allowed as Clone is a read operation. Every clone must share one clock so
+// that Diff skips their shared subtrees.
+func TestCloneConcurrent(t *testing.T) {
+	var calls int
+	tr := NewTree()
+	for i := 0; i < 1000; i++ {
+		tr.ReplaceOrInsert(versioned{k: i, calls: &calls})
+	}
+	clones := make([]*Tree, 8)
+	var wg sync.WaitGroup
+	for i := range clones {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			clones[i] = tr.Clone()
+			tr.Get(versioned{k: i})
+		}()
+	}
+	wg.Wait()
+	for i, c := range clones {
+		c.ReplaceOrInsert(versioned{k: i, version: 1, calls: &calls})
+	}
+	calls = 0
+	got := collectDiff(clones[0], clones[1])
+	require.Len(t, got, 2)
+	assert.Equal(t, 0, got[0].OldRank)
+	assert.Equal(t, 1, got[1].NewRank)
+	assert.True(t, calls < 50, "%d Equal calls", calls)
+}
@@ -0,0 +1,75 @@
+package orderstat
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteDOT writes a Graphviz DOT description of the structure of the tree to
+// w. Each node is labeled with label(item), its index in the node arena and
+// the size of its subtree. Links to red nodes are drawn in red. If label is
+// nil, items are formatted with fmt.Sprint.
+func (t *Tree) WriteDOT(w io.Writer, label func(Item) string) error {
+	if label == nil {
+		label = func(item Item) string { return fmt.Sprint(item) }
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph orderstat {")
+	fmt.Fprintln(bw, "\tnode [shape=box];")
+	t.root.writeDOT(t, bw, label)
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+func (it iterator) writeDOT(t *Tree, w io.Writer, label func(Item) string) {
+	if it.node == nil {
+		return
+	}
+	fmt.Fprintf(w, "\tn%d [label=%s, color=%s];\n", it.np,
+		strconv.Quote(fmt.Sprintf("%s\n#%d count=%d", label(it.item), it.np, it.count())),
+		colorName(it.isRed()))
+	for _, child := range []struct {
+		name string
+		it   iterator
+	}{{"L", it.l(t)}, {"R", it.r(t)}} {
+		if child.it.node == nil {
+			continue
+		}
+		fmt.Fprintf(w, "\tn%d -> n%d [label=%s, color=%s];\n",
+			it.np, child.it.np, child.name, colorName(child.it.isRed()))
+		child.it.writeDOT(t, w, label)
+	}
+}
+
+func colorName(red bool) string {
+	if red {
+		return "red"
+	}
+	return "black"
+}
+
+// Dump writes an indented description of the structure of the tree to w, one
+// node per line in pre-order. Each line shows whether the node is the left or
+// right child of its parent, its index in the node arena, its item, the size
+// of its subtree and its color.
+func (t *Tree) Dump(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if t.root.node == nil {
+		fmt.Fprintln(bw, "<empty>")
+	}
+	t.root.dump(t, bw, "*", 0)
+	return bw.Flush()
+}
+
+func (it iterator) dump(t *Tree, w io.Writer, side string, depth int) {
+	if it.node == nil {
+		return
+	}
+	fmt.Fprintf(w, "%s%s #%d %v count=%d %s\n", strings.Repeat("  ", depth),
+		side, it.np, it.item, it.count(), colorName(it.isRed()))
+	it.l(t).dump(t, w, "L", depth+1)
+	it.r(t).dump(t, w, "R", depth+1)
+}
@@ -0,0 +1,52 @@
+package orderstat
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDump(t *testing.T) {
+	tr := NewTree()
+	var buf bytes.Buffer
+	assert.Nil(t, tr.Dump(&buf))
+	assert.Equal(t, "<empty>\n", buf.String())
+
+	for _, k := range []string{"a", "b", "c", "d"} {
+		tr.ReplaceOrInsert(kv(k, ""))
+	}
+	buf.Reset()
+	assert.Nil(t, tr.Dump(&buf))
+	assert.Equal(t, `* #1 {b } count=4 black
+  L #0 {a } count=1 black
+  R #3 {d } count=2 black
+    L #2 {c } count=1 red
+`, buf.String())
+}
+
+func TestWriteDOT(t *testing.T) {
+	tr := NewTree()
+	for _, k := range []string{"a", "b", "c", "d"} {
+		tr.ReplaceOrInsert(kv(k, ""))
+	}
+	var buf bytes.Buffer
+	assert.Nil(t, tr.WriteDOT(&buf, func(item Item) string {
+		return `"` + item.(keyValue).k + `"`
+	}))
+	assert.Equal(t, `digraph orderstat {
+	node [shape=box];
+	n1 [label="\"b\"\n#1 count=4", color=black];
+	n1 -> n0 [label=L, color=black];
+	n0 [label="\"a\"\n#0 count=1", color=black];
+	n1 -> n3 [label=R, color=black];
+	n3 [label="\"d\"\n#3 count=2", color=black];
+	n3 -> n2 [label=L, color=red];
+	n2 [label="\"c\"\n#2 count=1", color=red];
+}
+`, buf.String())
+
+	buf.Reset()
+	assert.Nil(t, NewTree().WriteDOT(&buf, nil))
+	assert.Equal(t, "digraph orderstat {\n\tnode [shape=box];\n}\n", buf.String())
+}
@@ -0,0 +1,417 @@
+// Package durable provides an order statistic index which survives process
+// crashes by recording every mutation in a write-ahead log.
+//
+// An Index keeps its items in an orderstat.Tree in memory and stores two files
+// in its directory: a checkpoint holding every item as of some point in time,
+// and a log of the mutations made since that checkpoint. Open loads the
+// checkpoint and replays the log. Periodically the log is folded into a new
+// checkpoint so that it does not grow without bound.
+//
+// Every mutation is written to the log before it is applied to the tree, so
+// it survives a crash of the process once the mutating method returns. It
+// only survives a crash of the machine once Sync has returned. A record which
+// was only partially written when the machine crashed is detected with a
+// checksum and discarded, along with anything after it, when the log is next
+// opened.
+//
+// Logged mutations are idempotent: each records the item inserted or the item
+// deleted. This allows a crash between writing a new checkpoint and clearing
+// the log to be recovered by replaying the log over the new checkpoint.
+package durable
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ajwerner/orderstat"
+)
+
+// Codec converts items to and from their binary representation.
+type Codec interface {
+	// Encode appends the binary representation of item to dst.
+	Encode(dst []byte, item orderstat.Item) ([]byte, error)
+	// Decode returns the item represented by data. The item must not retain
+	// data, which may be reused.
+	Decode(data []byte) (orderstat.Item, error)
+}
+
+const (
+	checkpointName = "checkpoint"
+	logName        = "log"
+
+	// defaultCheckpointThreshold is the default size the log may reach before
+	// it is folded into a new checkpoint.
+	defaultCheckpointThreshold = 4 << 20
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Index is a durable order statistic index. It is not safe for concurrent
+// use by multiple goroutines.
+type Index struct {
+	dir   string
+	codec Codec
+	tree  *orderstat.Tree
+
+	log     *os.File
+	logSize int64
+	buf     []byte
+
+	checkpointThreshold int64
+}
+
+// Open opens the index stored in dir, creating it if it does not exist, and
+// recovers its contents from the checkpoint and the log.
+func Open(dir string, codec Codec) (*Index, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	idx := &Index{
+		dir:                 dir,
+		codec:               codec,
+		tree:                orderstat.NewTree(),
+		checkpointThreshold: defaultCheckpointThreshold,
+	}
+	if err := idx.loadCheckpoint(); err != nil {
+		return nil, err
+	}
+	if err := idx.openLog(); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// Tree returns the tree holding the contents of the index. It may be used for
+// any read operation but must not be modified directly; mutations must be
+// made through the Index so that they are logged.
+func (idx *Index) Tree() *orderstat.Tree {
+	return idx.tree
+}
+
+// SetCheckpointThreshold sets the size in bytes which the log may reach
+// before a mutation folds it into a new checkpoint. A threshold of zero or
+// less disables automatic checkpoints.
+func (idx *Index) SetCheckpointThreshold(size int64) {
+	idx.checkpointThreshold = size
+}
+
+// ReplaceOrInsert adds item to the index, returning the item it replaced, if
+// any.
+func (idx *Index) ReplaceOrInsert(item orderstat.Item) (replaced orderstat.Item, err error) {
+	if err := idx.append(opPut, item); err != nil {
+		return nil, err
+	}
+	return idx.tree.ReplaceOrInsert(item), idx.maybeCheckpoint()
+}
+
+// Delete removes the item equal to item from the index, returning it. If no
+// such item exists, it returns nil and nothing is logged.
+func (idx *Index) Delete(item orderstat.Item) (removed orderstat.Item, err error) {
+	existing := idx.tree.Get(item)
+	if existing == nil {
+		return nil, nil
+	}
+	return idx.delete(existing)
+}
+
+// DeleteMin removes the smallest item from the index and returns it. If the
+// index is empty, it returns nil.
+func (idx *Index) DeleteMin() (removed orderstat.Item, err error) {
+	if min := idx.tree.Min(); min != nil {
+		return idx.delete(min)
+	}
+	return nil, nil
+}
+
+// DeleteMax removes the largest item from the index and returns it. If the
+// index is empty, it returns nil.
+func (idx *Index) DeleteMax() (removed orderstat.Item, err error) {
+	if max := idx.tree.Max(); max != nil {
+		return idx.delete(max)
+	}
+	return nil, nil
+}
+
+func (idx *Index) delete(item orderstat.Item) (orderstat.Item, error) {
+	if err := idx.append(opDelete, item); err != nil {
+		return nil, err
+	}
+	return idx.tree.Delete(item), idx.maybeCheckpoint()
+}
+
+// Sync flushes the log to stable storage so that every mutation made so far
+// survives a crash of the machine.
+func (idx *Index) Sync() error {
+	return idx.log.Sync()
+}
+
+// Close syncs and closes the log. The index must not be used afterwards.
+func (idx *Index) Close() error {
+	err := idx.log.Sync()
+	if cerr := idx.log.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (idx *Index) maybeCheckpoint() error {
+	if idx.checkpointThreshold > 0 && idx.logSize >= idx.checkpointThreshold {
+		return idx.Checkpoint()
+	}
+	return nil
+}
+
+// Checkpoint writes the contents of the index to a new checkpoint and clears
+// the log. The checkpoint is synced to stable storage before it replaces the
+// previous one.
+func (idx *Index) Checkpoint() error {
+	if err := idx.writeCheckpoint(); err != nil {
+		return err
+	}
+	if err := idx.log.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := idx.log.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	idx.logSize = 0
+	return idx.log.Sync()
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Checkpoint
+////////////////////////////////////////////////////////////////////////////////
+
+// A checkpoint consists of checkpointMagic, the number of items as a uvarint,
+// each item as a uvarint length followed by its encoding, and finally the
+// CRC-32C of everything before it.
+var checkpointMagic = []byte("OSTATCK1")
+
+func (idx *Index) writeCheckpoint() error {
+	buf := append(idx.buf[:0], checkpointMagic...)
+	buf = binary.AppendUvarint(buf, uint64(idx.tree.Len()))
+	var err error
+	var enc []byte
+	idx.tree.Ascend(func(item orderstat.Item) bool {
+		if enc, err = idx.codec.Encode(enc[:0], item); err != nil {
+			return false
+		}
+		buf = binary.AppendUvarint(buf, uint64(len(enc)))
+		buf = append(buf, enc...)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	buf = binary.LittleEndian.AppendUint32(buf, crc32.Checksum(buf, crcTable))
+	idx.buf = buf[:0]
+
+	tmp := filepath.Join(idx.dir, checkpointName+".tmp")
+	if err := writeFileSync(tmp, buf); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(idx.dir, checkpointName)); err != nil {
+		return err
+	}
+	return syncDir(idx.dir)
+}
+
+func (idx *Index) loadCheckpoint() error {
+	data, err := os.ReadFile(filepath.Join(idx.dir, checkpointName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	corrupt := func(reason string) error {
+		return fmt.Errorf("durable: corrupt checkpoint in %s: %s", idx.dir, reason)
+	}
+	if len(data) < len(checkpointMagic)+4 || !bytes.Equal(data[:len(checkpointMagic)], checkpointMagic) {
+		return corrupt("bad header")
+	}
+	body, sum := data[:len(data)-4], binary.LittleEndian.Uint32(data[len(data)-4:])
+	if crc32.Checksum(body, crcTable) != sum {
+		return corrupt("checksum mismatch")
+	}
+	body = body[len(checkpointMagic):]
+	n, sz := binary.Uvarint(body)
+	if sz <= 0 {
+		return corrupt("bad item count")
+	}
+	body = body[sz:]
+	for i := uint64(0); i < n; i++ {
+		l, sz := binary.Uvarint(body)
+		if sz <= 0 || uint64(len(body)-sz) < l {
+			return corrupt("truncated item")
+		}
+		item, err := idx.codec.Decode(body[sz : sz+int(l)])
+		if err != nil {
+			return fmt.Errorf("durable: decoding checkpoint item %d: %v", i, err)
+		}
+		idx.tree.ReplaceOrInsert(item)
+		body = body[sz+int(l):]
+	}
+	if len(body) != 0 {
+		return corrupt("trailing data")
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Log
+////////////////////////////////////////////////////////////////////////////////
+
+// Each log record consists of a header holding the length of the payload and
+// its CRC-32C as little endian uint32s, followed by the payload: an op byte
+// and the encoded item.
+const recordHeaderSize = 8
+
+type op byte
+
+const (
+	opPut    op = 1
+	opDelete op = 2
+)
+
+func (idx *Index) append(o op, item orderstat.Item) error {
+	buf := append(idx.buf[:0], make([]byte, recordHeaderSize)...)
+	buf = append(buf, byte(o))
+	buf, err := idx.codec.Encode(buf, item)
+	if err != nil {
+		return err
+	}
+	payload := buf[recordHeaderSize:]
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(buf[4:8], crc32.Checksum(payload, crcTable))
+	idx.buf = buf[:0]
+	n, err := idx.log.Write(buf)
+	idx.logSize += int64(n)
+	if err != nil {
+		// Remove any partial record so that later records are not lost
+		// behind it when the log is replayed.
+		if terr := idx.truncateLog(idx.logSize - int64(n)); terr != nil {
+			return fmt.Errorf("%v (and truncating the log: %v)", err, terr)
+		}
+	}
+	return err
+}
+
+func (idx *Index) truncateLog(size int64) error {
+	if err := idx.log.Truncate(size); err != nil {
+		return err
+	}
+	if _, err := idx.log.Seek(size, io.SeekStart); err != nil {
+		return err
+	}
+	idx.logSize = size
+	return nil
+}
+
+// openLog opens the log and replays it into the tree. A torn or corrupt
+// record ends the log: it and anything after it are truncated away.
+func (idx *Index) openLog() error {
+	f, err := os.OpenFile(filepath.Join(idx.dir, logName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	idx.log = f
+	data, err := io.ReadAll(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	good, err := idx.replay(data)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	idx.logSize = int64(len(data))
+	if good < len(data) {
+		if err := idx.truncateLog(int64(good)); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return nil
+}
+
+// replay applies the records in data to the tree and returns the length of
+// the prefix of data holding intact records.
+func (idx *Index) replay(data []byte) (good int, err error) {
+	for off := 0; ; {
+		if len(data)-off < recordHeaderSize {
+			return off, nil
+		}
+		l := int(binary.LittleEndian.Uint32(data[off:]))
+		sum := binary.LittleEndian.Uint32(data[off+4:])
+		start := off + recordHeaderSize
+		if l < 1 || l > len(data)-start {
+			return off, nil
+		}
+		payload := data[start : start+l]
+		if crc32.Checksum(payload, crcTable) != sum {
+			return off, nil
+		}
+		item, err := idx.codec.Decode(payload[1:])
+		if err != nil {
+			return 0, fmt.Errorf("durable: decoding log record at offset %d: %v", off, err)
+		}
+		switch op(payload[0]) {
+		case opPut:
+			idx.tree.ReplaceOrInsert(item)
+		case opDelete:
+			idx.tree.Delete(item)
+		default:
+			return 0, fmt.Errorf("durable: unknown op %d at offset %d", payload[0], off)
+		}
+		off = start + l
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Files
+////////////////////////////////////////////////////////////////////////////////
+
+func writeFileSync(name string, data []byte) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// syncDir syncs the directory so that a rename within it is durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	// Some platforms do not support syncing directories.
+	if errors.Is(err, os.ErrInvalid) {
+		return nil
+	}
+	return err
+}
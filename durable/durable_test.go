@@ -0,0 +1,214 @@
+package durable
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ajwerner/orderstat"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type keyValue struct {
+	k uint64
+	v string
+}
+
+func (kv keyValue) Less(other orderstat.Item) bool { return kv.k < other.(keyValue).k }
+
+type kvCodec struct{}
+
+func (kvCodec) Encode(dst []byte, item orderstat.Item) ([]byte, error) {
+	kv := item.(keyValue)
+	dst = binary.AppendUvarint(dst, kv.k)
+	return append(dst, kv.v...), nil
+}
+
+func (kvCodec) Decode(data []byte) (orderstat.Item, error) {
+	k, n := binary.Uvarint(data)
+	if n <= 0 {
+		return nil, errors.New("bad key")
+	}
+	return keyValue{k: k, v: string(data[n:])}, nil
+}
+
+func contents(t *orderstat.Tree) (items []keyValue) {
+	t.Ascend(func(item orderstat.Item) bool {
+		items = append(items, item.(keyValue))
+		return true
+	})
+	return items
+}
+
+// mutate applies n random mutations to idx and to expected.
+func mutate(t *testing.T, rng *rand.Rand, idx *Index, expected map[uint64]string, n int) {
+	for i := 0; i < n; i++ {
+		kv := keyValue{k: uint64(rng.Intn(100)), v: string(rune('a' + rng.Intn(26)))}
+		var err error
+		switch rng.Intn(6) {
+		case 0, 1, 2:
+			_, err = idx.ReplaceOrInsert(kv)
+			expected[kv.k] = kv.v
+		case 3:
+			_, err = idx.Delete(kv)
+			delete(expected, kv.k)
+		case 4:
+			var min orderstat.Item
+			if min, err = idx.DeleteMin(); min != nil {
+				delete(expected, min.(keyValue).k)
+			}
+		case 5:
+			var max orderstat.Item
+			if max, err = idx.DeleteMax(); max != nil {
+				delete(expected, max.(keyValue).k)
+			}
+		}
+		require.NoError(t, err)
+	}
+}
+
+func checkContents(t *testing.T, idx *Index, expected map[uint64]string) {
+	t.Helper()
+	got := contents(idx.Tree())
+	require.Equal(t, len(expected), len(got))
+	for _, kv := range got {
+		require.Equal(t, expected[kv.k], kv.v, "key %d", kv.k)
+	}
+}
+
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+	rng := rand.New(rand.NewSource(1))
+	expected := map[uint64]string{}
+	for i := 0; i < 5; i++ {
+		idx, err := Open(dir, kvCodec{})
+		require.NoError(t, err)
+		checkContents(t, idx, expected)
+		mutate(t, rng, idx, expected, 500)
+		if i%2 == 1 {
+			require.NoError(t, idx.Checkpoint())
+		}
+		mutate(t, rng, idx, expected, 100)
+		require.NoError(t, idx.Sync())
+		checkContents(t, idx, expected)
+		require.NoError(t, idx.Close())
+	}
+}
+
+func TestAutomaticCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	idx, err := Open(dir, kvCodec{})
+	require.NoError(t, err)
+	idx.SetCheckpointThreshold(1 << 10)
+	rng := rand.New(rand.NewSource(1))
+	expected := map[uint64]string{}
+	mutate(t, rng, idx, expected, 2000)
+	require.NoError(t, idx.Close())
+
+	info, err := os.Stat(filepath.Join(dir, logName))
+	require.NoError(t, err)
+	assert.True(t, info.Size() < 1<<10, "log size %d", info.Size())
+	_, err = os.Stat(filepath.Join(dir, checkpointName))
+	require.NoError(t, err)
+
+	idx, err = Open(dir, kvCodec{})
+	require.NoError(t, err)
+	checkContents(t, idx, expected)
+	require.NoError(t, idx.Close())
+}
+
+// TestTornWrite simulates a crash in the middle of writing a log record by
+// truncating the log at every possible offset within its last record, and by
+// corrupting the last record.
+func TestTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	idx, err := Open(dir, kvCodec{})
+	require.NoError(t, err)
+	expected := map[uint64]string{}
+	mutate(t, rand.New(rand.NewSource(1)), idx, expected, 100)
+	require.NoError(t, idx.Close())
+	before, err := os.ReadFile(filepath.Join(dir, logName))
+	require.NoError(t, err)
+
+	idx, err = Open(dir, kvCodec{})
+	require.NoError(t, err)
+	last := keyValue{k: 1000, v: "last"}
+	_, err = idx.ReplaceOrInsert(last)
+	require.NoError(t, err)
+	require.NoError(t, idx.Close())
+	after, err := os.ReadFile(filepath.Join(dir, logName))
+	require.NoError(t, err)
+	require.Equal(t, before, after[:len(before)])
+
+	torn := [][]byte{append(append([]byte(nil), after...), 0xff)}
+	for n := len(before); n < len(after); n++ {
+		torn = append(torn, after[:n])
+	}
+	corrupt := append([]byte(nil), after...)
+	corrupt[len(corrupt)-1] ^= 0xff
+	torn = append(torn, corrupt)
+	for i, data := range torn {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, logName), data, 0644))
+		idx, err := Open(dir, kvCodec{})
+		require.NoError(t, err, "case %d", i)
+		if i == 0 {
+			assert.Equal(t, last, idx.Tree().Get(last), "case %d", i)
+		} else {
+			assert.Nil(t, idx.Tree().Get(last), "case %d", i)
+		}
+		idx.Tree().Delete(last)
+		checkContents(t, idx, expected)
+		// The torn record has been truncated so new records are not lost
+		// behind it.
+		_, err = idx.ReplaceOrInsert(keyValue{k: 2000, v: "new"})
+		require.NoError(t, err)
+		require.NoError(t, idx.Close())
+		idx, err = Open(dir, kvCodec{})
+		require.NoError(t, err)
+		assert.NotNil(t, idx.Tree().Get(keyValue{k: 2000}), "case %d", i)
+		require.NoError(t, idx.Close())
+	}
+}
+
+// TestCrashDuringCheckpoint simulates a crash after a new checkpoint has been
+// written but before the log was cleared by restoring the old log.
+func TestCrashDuringCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	idx, err := Open(dir, kvCodec{})
+	require.NoError(t, err)
+	expected := map[uint64]string{}
+	mutate(t, rand.New(rand.NewSource(1)), idx, expected, 1000)
+	require.NoError(t, idx.Sync())
+	oldLog, err := os.ReadFile(filepath.Join(dir, logName))
+	require.NoError(t, err)
+	require.NoError(t, idx.Checkpoint())
+	require.NoError(t, idx.Close())
+	require.NoError(t, os.WriteFile(filepath.Join(dir, logName), oldLog, 0644))
+
+	idx, err = Open(dir, kvCodec{})
+	require.NoError(t, err)
+	checkContents(t, idx, expected)
+	require.NoError(t, idx.Close())
+}
+
+func TestCorruptCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	idx, err := Open(dir, kvCodec{})
+	require.NoError(t, err)
+	_, err = idx.ReplaceOrInsert(keyValue{k: 1, v: "a"})
+	require.NoError(t, err)
+	require.NoError(t, idx.Checkpoint())
+	require.NoError(t, idx.Close())
+
+	name := filepath.Join(dir, checkpointName)
+	data, err := os.ReadFile(name)
+	require.NoError(t, err)
+	data[len(data)/2] ^= 0xff
+	require.NoError(t, os.WriteFile(name, data, 0644))
+	_, err = Open(dir, kvCodec{})
+	assert.Error(t, err)
+}
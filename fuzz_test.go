@@ -0,0 +1,181 @@
+package orderstat
+
+import (
+	"fmt"
+	"testing"
+)
+
+// fuzzModel is the set of byte keys which should be in the tree.
+type fuzzModel [256]bool
+
+func (m *fuzzModel) items(lo, hi int, descending bool) (items []Item) {
+	for i := lo; i < hi; i++ {
+		k := i
+		if descending {
+			k = hi - 1 - (i - lo)
+		}
+		if m[k] {
+			items = append(items, intItem(k))
+		}
+	}
+	return items
+}
+
+func (m *fuzzModel) len() (n int) {
+	for _, ok := range m {
+		if ok {
+			n++
+		}
+	}
+	return n
+}
+
+func (m *fuzzModel) rank(k int) int {
+	if !m[k] {
+		return -1
+	}
+	return len(m.items(0, k, false))
+}
+
+const (
+	fuzzReplaceOrInsert = iota
+	fuzzDelete
+	fuzzDeleteMin
+	fuzzDeleteMax
+	fuzzSelect
+	fuzzRank
+	fuzzAscend
+	fuzzAscendGreaterOrEqual
+	fuzzAscendLessThan
+	fuzzAscendRange
+	fuzzDescend
+	fuzzDescendGreaterThan
+	fuzzDescendLessOrEqual
+	fuzzDescendRange
+	numFuzzOps
+)
+
+// FuzzTree decodes its input into a sequence of operations, each encoded as
+// an opcode byte followed by two key bytes, and applies them to a tree and to
+// a model, checking that they agree and that the tree's invariants hold after
+// every operation.
+func FuzzTree(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{
+		fuzzReplaceOrInsert, 12, 0,
+		fuzzReplaceOrInsert, 61, 0,
+		fuzzDelete, 18, 0,
+	})
+	var seq []byte
+	for i := 0; i < 64; i++ {
+		seq = append(seq, fuzzReplaceOrInsert, byte(i*37), 0)
+	}
+	for i := 0; i < 64; i++ {
+		seq = append(seq, byte(i%numFuzzOps), byte(i*11), byte(i*13))
+	}
+	f.Add(seq)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		tr := NewTree()
+		var m fuzzModel
+		for step := 0; len(data) >= 3; step, data = step+1, data[3:] {
+			op, a, b := int(data[0])%numFuzzOps, int(data[1]), int(data[2])
+			desc := fmt.Sprintf("step %d: op %d(%d, %d)", step, op, a, b)
+			if err := fuzzApply(tr, &m, op, a, b); err != nil {
+				t.Fatalf("%s: %v", desc, err)
+			}
+			if tr.Len() != m.len() {
+				t.Fatalf("%s: Len() = %d, expected %d", desc, tr.Len(), m.len())
+			}
+			if err := tr.Validate(); err != nil {
+				t.Fatalf("%s: %v", desc, err)
+			}
+		}
+	})
+}
+
+func fuzzApply(tr *Tree, m *fuzzModel, op, a, b int) error {
+	expectItem := func(got Item, exp Item) error {
+		if got != exp {
+			return fmt.Errorf("got %v, expected %v", got, exp)
+		}
+		return nil
+	}
+	var visited []Item
+	collect := func(item Item) bool {
+		visited = append(visited, item)
+		return true
+	}
+	var expected []Item
+	switch op {
+	case fuzzReplaceOrInsert:
+		var exp Item
+		if m[a] {
+			exp = intItem(a)
+		}
+		m[a] = true
+		return expectItem(tr.ReplaceOrInsert(intItem(a)), exp)
+	case fuzzDelete:
+		var exp Item
+		if m[a] {
+			exp = intItem(a)
+		}
+		m[a] = false
+		return expectItem(tr.Delete(intItem(a)), exp)
+	case fuzzDeleteMin, fuzzDeleteMax:
+		var exp Item
+		if items := m.items(0, len(m), op == fuzzDeleteMax); len(items) > 0 {
+			exp = items[0]
+			m[exp.(intItem)] = false
+		}
+		if op == fuzzDeleteMin {
+			return expectItem(tr.DeleteMin(), exp)
+		}
+		return expectItem(tr.DeleteMax(), exp)
+	case fuzzSelect:
+		items := m.items(0, len(m), false)
+		i := a % (len(items) + 1)
+		var exp Item
+		if i < len(items) {
+			exp = items[i]
+		}
+		return expectItem(tr.Select(i), exp)
+	case fuzzRank:
+		if got, exp := tr.Rank(intItem(a)), m.rank(a); got != exp {
+			return fmt.Errorf("got rank %d, expected %d", got, exp)
+		}
+		return nil
+	case fuzzAscend:
+		tr.Ascend(collect)
+		expected = m.items(0, len(m), false)
+	case fuzzAscendGreaterOrEqual:
+		tr.AscendGreaterOrEqual(intItem(a), collect)
+		expected = m.items(a, len(m), false)
+	case fuzzAscendLessThan:
+		tr.AscendLessThan(intItem(a), collect)
+		expected = m.items(0, a, false)
+	case fuzzAscendRange:
+		tr.AscendRange(intItem(a), intItem(b), collect)
+		expected = m.items(a, b, false)
+	case fuzzDescend:
+		tr.Descend(collect)
+		expected = m.items(0, len(m), true)
+	case fuzzDescendGreaterThan:
+		tr.DescendGreaterThan(intItem(a), collect)
+		expected = m.items(a+1, len(m), true)
+	case fuzzDescendLessOrEqual:
+		tr.DescendLessOrEqual(intItem(a), collect)
+		expected = m.items(0, a+1, true)
+	case fuzzDescendRange:
+		tr.DescendRange(intItem(a), intItem(b), collect)
+		expected = m.items(b+1, a+1, true)
+	}
+	if len(visited) != len(expected) {
+		return fmt.Errorf("visited %v, expected %v", visited, expected)
+	}
+	for i := range visited {
+		if visited[i] != expected[i] {
+			return fmt.Errorf("visited %v, expected %v", visited, expected)
+		}
+	}
+	return nil
+}
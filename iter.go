@@ -0,0 +1,42 @@
+package orderstat
+
+import "iter"
+
+// All returns an iterator over every item in the tree in ascending order.
+func (t *Tree) All() iter.Seq[Item] {
+	return func(yield func(Item) bool) {
+		for it, ok := t.root.min(t); ok && yield(it.item); it, ok = it.next(t) {
+		}
+	}
+}
+
+// Backward returns an iterator over every item in the tree in descending
+// order.
+func (t *Tree) Backward() iter.Seq[Item] {
+	return func(yield func(Item) bool) {
+		for it, ok := t.root.max(t); ok && yield(it.item); it, ok = it.prev(t) {
+		}
+	}
+}
+
+// Range returns an iterator over the items in the tree within the range
+// [greaterOrEqual, lessThan) in ascending order.
+func (t *Tree) Range(greaterOrEqual, lessThan Item) iter.Seq[Item] {
+	return func(yield func(Item) bool) {
+		var it iterator
+		ok := it.seek(t, greaterOrEqual, seekGTE)
+		for ; ok && t.less(it.item, lessThan) && yield(it.item); it, ok = it.next(t) {
+		}
+	}
+}
+
+// Enumerate returns an iterator over every item in the tree in ascending
+// order, yielding the rank of each item alongside it.
+func (t *Tree) Enumerate() iter.Seq2[int, Item] {
+	return func(yield func(int, Item) bool) {
+		i := 0
+		for it, ok := t.root.min(t); ok && yield(i, it.item); it, ok = it.next(t) {
+			i++
+		}
+	}
+}
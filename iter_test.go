@@ -0,0 +1,52 @@
+package orderstat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIterators(t *testing.T) {
+	tr := NewTree()
+	const N = 100
+	for i := 0; i < N; i++ {
+		tr.ReplaceOrInsert(intItem(2 * i))
+	}
+	i := 0
+	for item := range tr.All() {
+		assert.Equal(t, intItem(2*i), item)
+		i++
+	}
+	assert.Equal(t, N, i)
+	for item := range tr.Backward() {
+		i--
+		assert.Equal(t, intItem(2*i), item)
+	}
+	assert.Equal(t, 0, i)
+	for rank, item := range tr.Enumerate() {
+		assert.Equal(t, rank, tr.Rank(item))
+		if rank == 10 {
+			break
+		}
+	}
+
+	var got []int
+	for item := range tr.Range(intItem(11), intItem(20)) {
+		got = append(got, int(item.(intItem)))
+	}
+	assert.Equal(t, []int{12, 14, 16, 18}, got)
+	got = got[:0]
+	for item := range tr.Range(intItem(10), intItem(20)) {
+		if item == intItem(14) {
+			break
+		}
+		got = append(got, int(item.(intItem)))
+	}
+	assert.Equal(t, []int{10, 12}, got)
+	for range tr.Range(intItem(11), intItem(12)) {
+		t.Fatal("expected empty range")
+	}
+	for range tr.Range(intItem(2*N), intItem(3*N)) {
+		t.Fatal("expected empty range")
+	}
+}
@@ -0,0 +1,127 @@
+package orderstat
+
+// BoundKind determines how a Bound constrains the items visited by Iterate.
+type BoundKind int
+
+const (
+	// Unbounded indicates that the range extends to the end of the tree.
+	Unbounded BoundKind = iota
+	// Inclusive indicates that an item equal to the bound is in the range.
+	Inclusive
+	// Exclusive indicates that an item equal to the bound is not in the range.
+	Exclusive
+)
+
+// Bound is one end of a range of items.
+type Bound struct {
+	Item Item
+	Kind BoundKind
+}
+
+// InclusiveBound returns a Bound which includes item.
+func InclusiveBound(item Item) Bound {
+	return Bound{Item: item, Kind: Inclusive}
+}
+
+// ExclusiveBound returns a Bound which excludes item.
+func ExclusiveBound(item Item) Bound {
+	return Bound{Item: item, Kind: Exclusive}
+}
+
+// Direction is the order in which Iterate visits items.
+type Direction int
+
+const (
+	// Ascending visits items from the lower bound to the upper bound.
+	Ascending Direction = iota
+	// Descending visits items from the upper bound to the lower bound.
+	Descending
+)
+
+// IterOptions describes the items visited by Iterate.
+//
+// The zero value visits every item in the tree in ascending order.
+type IterOptions struct {
+	// Lower is the lower bound of the range of items.
+	Lower Bound
+	// Upper is the upper bound of the range of items.
+	Upper Bound
+	// Direction is the order in which items are visited.
+	Direction Direction
+	// Limit, if positive, is the maximum number of items visited.
+	Limit int
+}
+
+// Iterate calls the iterator for every value in the tree within the range
+// described by opts, in the order described by opts, until iterator returns
+// false or opts.Limit items have been visited.
+func (t *Tree) Iterate(opts IterOptions, f ItemIterator) {
+	var it iterator
+	ok := it.seekStart(t, opts)
+	for n := 0; ok; n++ {
+		if opts.Limit > 0 && n >= opts.Limit {
+			return
+		}
+		if opts.Direction == Descending {
+			if opts.Lower.below(t.less, it.item) {
+				return
+			}
+		} else if opts.Upper.above(t.less, it.item) {
+			return
+		}
+		if !f(it.item) {
+			return
+		}
+		if opts.Direction == Descending {
+			it, ok = it.prev(t)
+		} else {
+			it, ok = it.next(t)
+		}
+	}
+}
+
+// seekStart positions it at the first item to be visited by Iterate.
+func (it *iterator) seekStart(t *Tree, opts IterOptions) (ok bool) {
+	if opts.Direction == Descending {
+		switch opts.Upper.Kind {
+		case Inclusive:
+			return it.seek(t, opts.Upper.Item, seekLTE)
+		case Exclusive:
+			return it.seek(t, opts.Upper.Item, seekLT)
+		}
+		*it, ok = t.root.max(t)
+		return ok
+	}
+	switch opts.Lower.Kind {
+	case Inclusive:
+		return it.seek(t, opts.Lower.Item, seekGTE)
+	case Exclusive:
+		return it.seek(t, opts.Lower.Item, seekGT)
+	}
+	*it, ok = t.root.min(t)
+	return ok
+}
+
+// above returns true if item lies beyond b when b is used as an upper bound.
+func (b Bound) above(less func(a, b Item) bool, item Item) bool {
+	switch b.Kind {
+	case Inclusive:
+		return less(b.Item, item)
+	case Exclusive:
+		return !less(item, b.Item)
+	default:
+		return false
+	}
+}
+
+// below returns true if item lies beyond b when b is used as a lower bound.
+func (b Bound) below(less func(a, b Item) bool, item Item) bool {
+	switch b.Kind {
+	case Inclusive:
+		return less(item, b.Item)
+	case Exclusive:
+		return !less(b.Item, item)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,114 @@
+package orderstat
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIterate(t *testing.T) {
+	const N = 20
+	tr := NewTree()
+	var all []int
+	for i := 0; i < N; i++ {
+		tr.ReplaceOrInsert(intItem(2 * i))
+		all = append(all, 2*i)
+	}
+	pivots := []int{-1, 0, 1, 6, 7, 2*N - 2, 2*N - 1}
+	kinds := []BoundKind{Unbounded, Inclusive, Exclusive}
+	inLower := func(b Bound, v int) bool {
+		switch b.Kind {
+		case Inclusive:
+			return v >= int(b.Item.(intItem))
+		case Exclusive:
+			return v > int(b.Item.(intItem))
+		}
+		return true
+	}
+	inUpper := func(b Bound, v int) bool {
+		switch b.Kind {
+		case Inclusive:
+			return v <= int(b.Item.(intItem))
+		case Exclusive:
+			return v < int(b.Item.(intItem))
+		}
+		return true
+	}
+	for _, lk := range kinds {
+		for _, uk := range kinds {
+			for _, lp := range pivots {
+				for _, up := range pivots {
+					for _, dir := range []Direction{Ascending, Descending} {
+						for _, limit := range []int{0, 1, 3} {
+							opts := IterOptions{
+								Lower:     Bound{Item: intItem(lp), Kind: lk},
+								Upper:     Bound{Item: intItem(up), Kind: uk},
+								Direction: dir,
+								Limit:     limit,
+							}
+							var exp []int
+							for i := range all {
+								v := all[i]
+								if dir == Descending {
+									v = all[len(all)-1-i]
+								}
+								if inLower(opts.Lower, v) && inUpper(opts.Upper, v) {
+									exp = append(exp, v)
+								}
+							}
+							if limit > 0 && len(exp) > limit {
+								exp = exp[:limit]
+							}
+							var got []int
+							tr.Iterate(opts, func(item Item) bool {
+								got = append(got, int(item.(intItem)))
+								return true
+							})
+							assert.Equal(t, exp, got, fmt.Sprintf("%+v", opts))
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestRangeBoundaries(t *testing.T) {
+	tr := NewTree()
+	for i := 0; i < 10; i++ {
+		tr.ReplaceOrInsert(intItem(2 * i))
+	}
+	collect := func(do func(ItemIterator)) (got []int) {
+		do(func(item Item) bool {
+			got = append(got, int(item.(intItem)))
+			return true
+		})
+		return got
+	}
+	// Empty ranges which fall between two items must not visit anything.
+	assert.Nil(t, collect(func(f ItemIterator) {
+		tr.AscendRange(intItem(11), intItem(12), f)
+	}))
+	assert.Nil(t, collect(func(f ItemIterator) {
+		tr.DescendRange(intItem(11), intItem(10), f)
+	}))
+	assert.Nil(t, collect(func(f ItemIterator) {
+		tr.AscendRange(intItem(12), intItem(4), f)
+	}))
+	assert.Equal(t, []int{0, 2}, collect(func(f ItemIterator) {
+		tr.AscendLessThan(intItem(4), f)
+	}))
+	assert.Equal(t, []int{4, 6}, collect(func(f ItemIterator) {
+		tr.AscendRange(intItem(4), intItem(8), f)
+	}))
+	assert.Equal(t, []int{8, 6}, collect(func(f ItemIterator) {
+		tr.DescendRange(intItem(8), intItem(4), f)
+	}))
+	assert.Equal(t, []int{18, 16}, collect(func(f ItemIterator) {
+		tr.DescendGreaterThan(intItem(14), f)
+	}))
+	assert.Equal(t, []int{2, 0}, collect(func(f ItemIterator) {
+		tr.DescendLessOrEqual(intItem(3), f)
+	}))
+}
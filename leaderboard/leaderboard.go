@@ -0,0 +1,180 @@
+// Package leaderboard ranks members by score on top of an orderstat.Tree.
+//
+// A Leaderboard holds at most one score per member. Members are ranked from
+// the highest score to the lowest, and members with equal scores are ranked
+// in ascending order of their names so that every member has a
+// deterministic position. A hash index from member to score allows the
+// current entry of a member to be found and removed when its score changes.
+package leaderboard
+
+import (
+	"math"
+	"sync"
+
+	"github.com/ajwerner/orderstat"
+)
+
+// Entry is a member along with its score.
+type Entry struct {
+	Member string
+	Score  float64
+}
+
+// Less orders entries by descending score and then by ascending member.
+func (e Entry) Less(other orderstat.Item) bool {
+	o := other.(Entry)
+	if e.Score != o.Score {
+		return e.Score > o.Score
+	}
+	return e.Member < o.Member
+}
+
+// Leaderboard is a set of members ranked by score.
+//
+// Leaderboard is safe for concurrent use by multiple goroutines. Each method
+// observes and leaves the leaderboard in a consistent state, so a member is
+// never seen with two entries or none while its score is being changed.
+type Leaderboard struct {
+	mu     sync.RWMutex
+	tree   *orderstat.Tree
+	scores map[string]float64
+}
+
+// New creates a new, empty Leaderboard.
+func New() *Leaderboard {
+	return &Leaderboard{
+		tree:   orderstat.NewTree(),
+		scores: make(map[string]float64),
+	}
+}
+
+// Len returns the number of members in the leaderboard.
+func (lb *Leaderboard) Len() int {
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+	return lb.tree.Len()
+}
+
+// SetScore sets the score of member, adding it if it is not yet present. It
+// returns the previous score of member, if any. SetScore panics if score is
+// NaN.
+func (lb *Leaderboard) SetScore(member string, score float64) (prev float64, existed bool) {
+	if math.IsNaN(score) {
+		panic("leaderboard: NaN score")
+	}
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return lb.setScoreLocked(member, score)
+}
+
+// IncrScore adds delta to the score of member, treating an absent member as
+// having a score of zero, and returns the new score. IncrScore panics if the
+// new score is NaN.
+func (lb *Leaderboard) IncrScore(member string, delta float64) float64 {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	score := lb.scores[member] + delta
+	if math.IsNaN(score) {
+		panic("leaderboard: NaN score")
+	}
+	lb.setScoreLocked(member, score)
+	return score
+}
+
+func (lb *Leaderboard) setScoreLocked(member string, score float64) (prev float64, existed bool) {
+	if prev, existed = lb.scores[member]; existed {
+		if prev == score {
+			return prev, true
+		}
+		lb.tree.Delete(Entry{Member: member, Score: prev})
+	}
+	lb.scores[member] = score
+	lb.tree.ReplaceOrInsert(Entry{Member: member, Score: score})
+	return prev, existed
+}
+
+// Remove removes member from the leaderboard. It returns false if member was
+// not present.
+func (lb *Leaderboard) Remove(member string) bool {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	score, ok := lb.scores[member]
+	if !ok {
+		return false
+	}
+	delete(lb.scores, member)
+	lb.tree.Delete(Entry{Member: member, Score: score})
+	return true
+}
+
+// Score returns the score of member. The second return value is false if
+// member is not present.
+func (lb *Leaderboard) Score(member string) (float64, bool) {
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+	score, ok := lb.scores[member]
+	return score, ok
+}
+
+// Rank returns the position of member in the leaderboard, where the member
+// with the highest score has rank 0. It returns -1 if member is not present.
+func (lb *Leaderboard) Rank(member string) int {
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+	score, ok := lb.scores[member]
+	if !ok {
+		return -1
+	}
+	return lb.tree.Rank(Entry{Member: member, Score: score})
+}
+
+// At returns the entry with the given rank. The second return value is false
+// if rank is out of range.
+func (lb *Leaderboard) At(rank int) (Entry, bool) {
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+	if item := lb.tree.Select(rank); item != nil {
+		return item.(Entry), true
+	}
+	return Entry{}, false
+}
+
+// Top returns the n highest ranked entries in rank order.
+func (lb *Leaderboard) Top(n int) []Entry {
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+	return lb.rangeLocked(0, n)
+}
+
+// Around returns member along with up to n entries ranked directly above it
+// and up to n entries ranked directly below it, in rank order. The rank of
+// the first returned entry is returned as first. If member is not present,
+// Around returns nil and -1.
+func (lb *Leaderboard) Around(member string, n int) (entries []Entry, first int) {
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+	score, ok := lb.scores[member]
+	if !ok {
+		return nil, -1
+	}
+	rank := lb.tree.Rank(Entry{Member: member, Score: score})
+	first = max(rank-max(n, 0), 0)
+	return lb.rangeLocked(first, rank+max(n, 0)+1-first), first
+}
+
+// rangeLocked returns up to n entries starting at rank first.
+func (lb *Leaderboard) rangeLocked(first, n int) []Entry {
+	start := lb.tree.Select(first)
+	if start == nil || n <= 0 {
+		return nil
+	}
+	entries := make([]Entry, 0, min(n, lb.tree.Len()-first))
+	lb.tree.Iterate(orderstat.IterOptions{
+		Lower: orderstat.InclusiveBound(start),
+		Limit: n,
+	}, func(item orderstat.Item) bool {
+		entries = append(entries, item.(Entry))
+		return true
+	})
+	return entries
+}
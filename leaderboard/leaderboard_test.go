@@ -0,0 +1,129 @@
+package leaderboard
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeaderboard(t *testing.T) {
+	lb := New()
+	for _, e := range []Entry{
+		{"carol", 30}, {"alice", 10}, {"dave", 20}, {"bob", 20}, {"erin", 5},
+	} {
+		_, existed := lb.SetScore(e.Member, e.Score)
+		assert.False(t, existed)
+	}
+	assert.Equal(t, 5, lb.Len())
+	assert.Equal(t, []Entry{{"carol", 30}, {"bob", 20}, {"dave", 20}}, lb.Top(3))
+	assert.Equal(t, 1, lb.Rank("bob"))
+	assert.Equal(t, 2, lb.Rank("dave"))
+	assert.Equal(t, -1, lb.Rank("zed"))
+
+	entries, first := lb.Around("dave", 1)
+	assert.Equal(t, []Entry{{"bob", 20}, {"dave", 20}, {"alice", 10}}, entries)
+	assert.Equal(t, 1, first)
+	entries, first = lb.Around("carol", 2)
+	assert.Equal(t, []Entry{{"carol", 30}, {"bob", 20}, {"dave", 20}}, entries)
+	assert.Equal(t, 0, first)
+	entries, first = lb.Around("erin", 1)
+	assert.Equal(t, []Entry{{"alice", 10}, {"erin", 5}}, entries)
+	assert.Equal(t, 3, first)
+	entries, first = lb.Around("erin", 0)
+	assert.Equal(t, []Entry{{"erin", 5}}, entries)
+	assert.Equal(t, 4, first)
+	entries, first = lb.Around("zed", 1)
+	assert.Nil(t, entries)
+	assert.Equal(t, -1, first)
+
+	prev, existed := lb.SetScore("erin", 25)
+	assert.True(t, existed)
+	assert.Equal(t, 5.0, prev)
+	assert.Equal(t, 1, lb.Rank("erin"))
+	assert.Equal(t, 5, lb.Len())
+	assert.Equal(t, 31.0, lb.IncrScore("alice", 21))
+	assert.Equal(t, 0, lb.Rank("alice"))
+	assert.Equal(t, 3.0, lb.IncrScore("frank", 3))
+	assert.Equal(t, 5, lb.Rank("frank"))
+
+	e, ok := lb.At(5)
+	assert.True(t, ok)
+	assert.Equal(t, Entry{"frank", 3}, e)
+	_, ok = lb.At(6)
+	assert.False(t, ok)
+
+	assert.True(t, lb.Remove("alice"))
+	assert.False(t, lb.Remove("alice"))
+	_, ok = lb.Score("alice")
+	assert.False(t, ok)
+	assert.Equal(t, 0, lb.Rank("carol"))
+	assert.Nil(t, lb.Top(0))
+	assert.Len(t, lb.Top(100), 5)
+
+	assert.Panics(t, func() { lb.SetScore("nan", math.NaN()) })
+}
+
+func TestLeaderboardRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	lb := New()
+	scores := map[string]float64{}
+	for i := 0; i < 5000; i++ {
+		member := fmt.Sprintf("m%d", rng.Intn(200))
+		switch rng.Intn(4) {
+		case 0:
+			assert.Equal(t, hasKey(scores, member), lb.Remove(member))
+			delete(scores, member)
+		case 1:
+			scores[member] += 1
+			assert.Equal(t, scores[member], lb.IncrScore(member, 1))
+		default:
+			score := float64(rng.Intn(20))
+			lb.SetScore(member, score)
+			scores[member] = score
+		}
+	}
+	want := make([]Entry, 0, len(scores))
+	for m, s := range scores {
+		want = append(want, Entry{m, s})
+	}
+	sort.Slice(want, func(i, j int) bool { return want[i].Less(want[j]) })
+	require.Equal(t, want, lb.Top(len(want)+1))
+	for i, e := range want {
+		assert.Equal(t, i, lb.Rank(e.Member))
+	}
+}
+
+func hasKey(m map[string]float64, k string) bool {
+	_, ok := m[k]
+	return ok
+}
+
+func TestLeaderboardConcurrent(t *testing.T) {
+	lb := New()
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				member := fmt.Sprintf("m%d", i%50)
+				lb.IncrScore(member, float64(w))
+				lb.Rank(member)
+				lb.Around(member, 2)
+			}
+		}(w)
+	}
+	wg.Wait()
+	assert.Equal(t, 50, lb.Len())
+	for i := 0; i < 50; i++ {
+		score, ok := lb.Score(fmt.Sprintf("m%d", i))
+		assert.True(t, ok)
+		assert.Equal(t, 20.0*6, score)
+	}
+}
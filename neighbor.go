@@ -0,0 +1,53 @@
+package orderstat
+
+// Floor returns the largest item in the tree which is less than or equal to
+// item along with its rank. If no such item exists, it returns nil and -1.
+func (t *Tree) Floor(item Item) (Item, int) {
+	return t.neighbor(item, seekLTE)
+}
+
+// Ceiling returns the smallest item in the tree which is greater than or equal
+// to item along with its rank. If no such item exists, it returns nil and -1.
+func (t *Tree) Ceiling(item Item) (Item, int) {
+	return t.neighbor(item, seekGTE)
+}
+
+// Lower returns the largest item in the tree which is strictly less than item
+// along with its rank. If no such item exists, it returns nil and -1.
+func (t *Tree) Lower(item Item) (Item, int) {
+	return t.neighbor(item, seekLT)
+}
+
+// Higher returns the smallest item in the tree which is strictly greater than
+// item along with its rank. If no such item exists, it returns nil and -1.
+func (t *Tree) Higher(item Item) (Item, int) {
+	return t.neighbor(item, seekGT)
+}
+
+// Nearest returns the item in the tree closest to item according to dist along
+// with its rank. Only the Floor and Ceiling of item are considered, so dist
+// must grow as items move apart in the tree's order, as is the case for the
+// absolute difference of numeric keys. Ties are broken in favor of the
+// smaller item. If the tree is empty, it returns nil and -1.
+func (t *Tree) Nearest(item Item, dist func(a, b Item) float64) (Item, int) {
+	floor, floorRank := t.Floor(item)
+	ceil, ceilRank := t.Ceiling(item)
+	switch {
+	case floor == nil:
+		return ceil, ceilRank
+	case ceil == nil:
+		return floor, floorRank
+	case dist(item, ceil) < dist(item, floor):
+		return ceil, ceilRank
+	default:
+		return floor, floorRank
+	}
+}
+
+func (t *Tree) neighbor(item Item, mode seekMode) (Item, int) {
+	var it iterator
+	if !it.seek(t, item, mode) {
+		return nil, -1
+	}
+	return it.item, it.rank(t)
+}
@@ -0,0 +1,58 @@
+package orderstat
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNeighbors(t *testing.T) {
+	tr := NewTree()
+	empty := func(item Item, rank int) {
+		assert.Nil(t, item)
+		assert.Equal(t, -1, rank)
+	}
+	empty(tr.Floor(intItem(1)))
+	empty(tr.Ceiling(intItem(1)))
+	empty(tr.Nearest(intItem(1), nil))
+
+	const N = 100
+	for _, i := range rand.Perm(N) {
+		tr.ReplaceOrInsert(intItem(10 * i))
+	}
+	expect := func(exp int) func(Item, int) {
+		return func(item Item, rank int) {
+			assert.Equal(t, intItem(10*exp), item)
+			assert.Equal(t, exp, rank)
+		}
+	}
+	for i := 0; i < N; i++ {
+		expect(i)(tr.Floor(intItem(10 * i)))
+		expect(i)(tr.Floor(intItem(10*i + 5)))
+		expect(i)(tr.Ceiling(intItem(10 * i)))
+		expect(i)(tr.Ceiling(intItem(10*i - 5)))
+		expect(i)(tr.Lower(intItem(10*i + 1)))
+		expect(i)(tr.Higher(intItem(10*i - 1)))
+		if i > 0 {
+			expect(i - 1)(tr.Lower(intItem(10 * i)))
+		}
+		if i < N-1 {
+			expect(i + 1)(tr.Higher(intItem(10 * i)))
+		}
+	}
+	empty(tr.Floor(intItem(-1)))
+	empty(tr.Lower(intItem(0)))
+	empty(tr.Ceiling(intItem(10*N - 9)))
+	empty(tr.Higher(intItem(10 * (N - 1))))
+
+	dist := func(a, b Item) float64 {
+		return math.Abs(float64(a.(intItem) - b.(intItem)))
+	}
+	expect(0)(tr.Nearest(intItem(-100), dist))
+	expect(3)(tr.Nearest(intItem(34), dist))
+	expect(3)(tr.Nearest(intItem(35), dist))
+	expect(4)(tr.Nearest(intItem(36), dist))
+	expect(N - 1)(tr.Nearest(intItem(10*N+100), dist))
+}
@@ -0,0 +1,22 @@
+package orderstat
+
+// Observer receives notifications of mutations made to a Tree. Each callback
+// is invoked after the mutation has been applied, along with the rank of the
+// affected item, which is computed during the mutation at no additional
+// asymptotic cost.
+type Observer interface {
+	// OnInsert is called when item is added to the tree at rank.
+	OnInsert(item Item, rank int)
+	// OnReplace is called when new replaces old, an equal item at rank.
+	OnReplace(old, new Item, rank int)
+	// OnDelete is called when item is removed from the tree. The rank is the
+	// rank the item had before it was removed.
+	OnDelete(item Item, rank int)
+}
+
+// SetObserver registers o to be notified of mutations made by ReplaceOrInsert,
+// Delete, DeleteMin, DeleteMax and the methods built on them. Passing nil
+// removes the current observer.
+func (t *Tree) SetObserver(o Observer) {
+	t.observer = o
+}
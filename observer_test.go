@@ -0,0 +1,92 @@
+package orderstat
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type event struct {
+	kind     string
+	old, new Item
+	rank     int
+}
+
+type recordingObserver struct {
+	events []event
+}
+
+func (o *recordingObserver) OnInsert(item Item, rank int) {
+	o.events = append(o.events, event{kind: "insert", new: item, rank: rank})
+}
+
+func (o *recordingObserver) OnReplace(old, new Item, rank int) {
+	o.events = append(o.events, event{kind: "replace", old: old, new: new, rank: rank})
+}
+
+func (o *recordingObserver) OnDelete(item Item, rank int) {
+	o.events = append(o.events, event{kind: "delete", old: item, rank: rank})
+}
+
+func (o *recordingObserver) pop() (e event, ok bool) {
+	if len(o.events) == 0 {
+		return e, false
+	}
+	e, o.events = o.events[0], o.events[1:]
+	return e, len(o.events) == 0
+}
+
+func TestObserver(t *testing.T) {
+	tr := NewTree()
+	var o recordingObserver
+	tr.SetObserver(&o)
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		k := rng.Intn(200)
+		v := kv(string(rune('A'+k/26))+string(rune('a'+k%26)), "")
+		switch rng.Intn(5) {
+		case 0, 1:
+			v.v = "new"
+			existed := tr.Has(v)
+			tr.ReplaceOrInsert(v)
+			e, ok := o.pop()
+			assert.True(t, ok)
+			if existed {
+				assert.Equal(t, "replace", e.kind)
+				assert.Equal(t, v.k, e.old.(keyValue).k)
+			} else {
+				assert.Equal(t, "insert", e.kind)
+			}
+			assert.Equal(t, v, e.new)
+			assert.Equal(t, tr.Rank(v), e.rank)
+		case 2:
+			rank := tr.Rank(v)
+			removed := tr.Delete(v)
+			if removed == nil {
+				assert.Empty(t, o.events)
+				continue
+			}
+			e, ok := o.pop()
+			assert.True(t, ok)
+			assert.Equal(t, event{kind: "delete", old: removed, rank: rank}, e)
+		case 3:
+			if removed := tr.DeleteMin(); removed != nil {
+				e, ok := o.pop()
+				assert.True(t, ok)
+				assert.Equal(t, event{kind: "delete", old: removed, rank: 0}, e)
+			}
+		case 4:
+			n := tr.Len()
+			if removed := tr.DeleteMax(); removed != nil {
+				e, ok := o.pop()
+				assert.True(t, ok)
+				assert.Equal(t, event{kind: "delete", old: removed, rank: n - 1}, e)
+			}
+		}
+		assert.Empty(t, o.events)
+	}
+	tr.SetObserver(nil)
+	tr.ReplaceOrInsert(kv("zz", ""))
+	assert.Empty(t, o.events)
+}
@@ -0,0 +1,106 @@
+package orderstattest
+
+import (
+	"sort"
+
+	"github.com/ajwerner/orderstat"
+)
+
+// model is the reference implementation against which the tree is checked.
+// It holds the items in a slice sorted by less.
+type model struct {
+	less  func(a, b orderstat.Item) bool
+	items []orderstat.Item
+}
+
+// search returns the index of the first item not less than item and whether
+// that item is equal to item.
+func (m *model) search(item orderstat.Item) (int, bool) {
+	i := sort.Search(len(m.items), func(i int) bool {
+		return !m.less(m.items[i], item)
+	})
+	return i, i < len(m.items) && !m.less(item, m.items[i])
+}
+
+// at returns the item at index i or nil if i is out of range.
+func (m *model) at(i int) orderstat.Item {
+	if i < 0 || i >= len(m.items) {
+		return nil
+	}
+	return m.items[i]
+}
+
+func (m *model) replaceOrInsert(item orderstat.Item) (replaced orderstat.Item) {
+	i, ok := m.search(item)
+	if ok {
+		replaced, m.items[i] = m.items[i], item
+		return replaced
+	}
+	m.items = append(m.items, nil)
+	copy(m.items[i+1:], m.items[i:])
+	m.items[i] = item
+	return nil
+}
+
+func (m *model) delete(item orderstat.Item) (removed orderstat.Item) {
+	i, ok := m.search(item)
+	if !ok {
+		return nil
+	}
+	removed = m.items[i]
+	m.items = append(m.items[:i], m.items[i+1:]...)
+	return removed
+}
+
+func (m *model) deleteIf(pred func(orderstat.Item) bool) (removed int) {
+	kept := m.items[:0]
+	for _, item := range m.items {
+		if pred(item) {
+			removed++
+		} else {
+			kept = append(kept, item)
+		}
+	}
+	m.items = kept
+	return removed
+}
+
+// iterate returns the items visited by Iterate with opts when the iterator
+// stops after stop items, where zero means never.
+func (m *model) iterate(opts orderstat.IterOptions, stop int) []orderstat.Item {
+	var visited []orderstat.Item
+	for i := range m.items {
+		item := m.items[i]
+		if opts.Direction == orderstat.Descending {
+			item = m.items[len(m.items)-1-i]
+		}
+		if !m.aboveLower(opts.Lower, item) || !m.belowUpper(opts.Upper, item) {
+			continue
+		}
+		visited = append(visited, item)
+		if (stop > 0 && len(visited) == stop) || (opts.Limit > 0 && len(visited) == opts.Limit) {
+			break
+		}
+	}
+	return visited
+}
+
+func (m *model) aboveLower(b orderstat.Bound, item orderstat.Item) bool {
+	switch b.Kind {
+	case orderstat.Inclusive:
+		return !m.less(item, b.Item)
+	case orderstat.Exclusive:
+		return m.less(b.Item, item)
+	}
+	return true
+}
+
+func (m *model) belowUpper(b orderstat.Bound, item orderstat.Item) bool {
+	switch b.Kind {
+	case orderstat.Inclusive:
+		return !m.less(b.Item, item)
+	case orderstat.Exclusive:
+		return m.less(item, b.Item)
+	}
+	return true
+}
@@ -0,0 +1,440 @@
+package orderstattest
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/ajwerner/orderstat"
+)
+
+type opKind int
+
+const (
+	opReplaceOrInsert opKind = iota
+	opDelete
+	opDeleteMin
+	opDeleteMax
+	opDeleteIf
+	opGet
+	opHas
+	opMin
+	opMax
+	opRank
+	opSelect
+	opFloor
+	opCeiling
+	opLower
+	opHigher
+	opNearest
+	opAscend
+	opAscendGreaterOrEqual
+	opAscendLessThan
+	opAscendRange
+	opDescend
+	opDescendGreaterThan
+	opDescendLessOrEqual
+	opDescendRange
+	opIterate
+	opAscendMutable
+	opAll
+	opBackward
+	opRange
+	opEnumerate
+	numOpKinds
+)
+
+var opNames = [numOpKinds]string{
+	opReplaceOrInsert:      "ReplaceOrInsert",
+	opDelete:               "Delete",
+	opDeleteMin:            "DeleteMin",
+	opDeleteMax:            "DeleteMax",
+	opDeleteIf:             "DeleteIf",
+	opGet:                  "Get",
+	opHas:                  "Has",
+	opMin:                  "Min",
+	opMax:                  "Max",
+	opRank:                 "Rank",
+	opSelect:               "Select",
+	opFloor:                "Floor",
+	opCeiling:              "Ceiling",
+	opLower:                "Lower",
+	opHigher:               "Higher",
+	opNearest:              "Nearest",
+	opAscend:               "Ascend",
+	opAscendGreaterOrEqual: "AscendGreaterOrEqual",
+	opAscendLessThan:       "AscendLessThan",
+	opAscendRange:          "AscendRange",
+	opDescend:              "Descend",
+	opDescendGreaterThan:   "DescendGreaterThan",
+	opDescendLessOrEqual:   "DescendLessOrEqual",
+	opDescendRange:         "DescendRange",
+	opIterate:              "Iterate",
+	opAscendMutable:        "AscendMutable",
+	opAll:                  "All",
+	opBackward:             "Backward",
+	opRange:                "Range",
+	opEnumerate:            "Enumerate",
+}
+
+// opWeights determines how often each kind of operation is generated.
+// Mutations are weighted so that the tree tends to grow while still seeing
+// plenty of deletions.
+var opWeights = func() (w [numOpKinds]int) {
+	for k := range w {
+		w[k] = 1
+	}
+	w[opReplaceOrInsert] = 12
+	w[opDelete] = 6
+	w[opDeleteMin] = 2
+	w[opDeleteMax] = 2
+	return w
+}()
+
+// op is a single operation to apply to the tree and the model. Which fields
+// are used depends on the kind.
+type op struct {
+	kind opKind
+	a, b orderstat.Item
+	// n is the index for Select, the number of quarters of the way from
+	// the preceding item to the next one at which Nearest places an absent
+	// item, or the number of items after which the iterator stops for
+	// iteration, where zero visits all items.
+	n    int
+	opts orderstat.IterOptions
+}
+
+func (o op) String() string {
+	switch o.kind {
+	case opReplaceOrInsert, opDelete, opGet, opHas, opRank,
+		opFloor, opCeiling, opLower, opHigher:
+		return fmt.Sprintf("%s(%v)", opNames[o.kind], o.a)
+	case opDeleteIf:
+		return fmt.Sprintf("DeleteIf([%v, %v))", o.a, o.b)
+	case opSelect:
+		return fmt.Sprintf("Select(%d)", o.n)
+	case opNearest:
+		return fmt.Sprintf("Nearest(%v, offset=%d/4)", o.a, o.n)
+	case opAscend, opDescend, opAll, opBackward, opEnumerate:
+		return fmt.Sprintf("%s(stop=%d)", opNames[o.kind], o.n)
+	case opAscendMutable:
+		return fmt.Sprintf("AscendMutable(insert=%v, delete below %v, stop=%d)", o.a, o.b, o.n)
+	case opAscendGreaterOrEqual, opAscendLessThan,
+		opDescendGreaterThan, opDescendLessOrEqual:
+		return fmt.Sprintf("%s(%v, stop=%d)", opNames[o.kind], o.a, o.n)
+	case opAscendRange, opDescendRange, opRange:
+		return fmt.Sprintf("%s(%v, %v, stop=%d)", opNames[o.kind], o.a, o.b, o.n)
+	case opIterate:
+		return fmt.Sprintf("Iterate(%+v, stop=%d)", o.opts, o.n)
+	default:
+		return opNames[o.kind] + "()"
+	}
+}
+
+func generate(rng *rand.Rand, gen func(*rand.Rand) orderstat.Item, n int) []op {
+	total := 0
+	for _, w := range opWeights {
+		total += w
+	}
+	ops := make([]op, n)
+	for i := range ops {
+		r := rng.Intn(total)
+		kind := opKind(0)
+		for ; r >= opWeights[kind]; kind++ {
+			r -= opWeights[kind]
+		}
+		o := op{kind: kind, a: gen(rng), b: gen(rng)}
+		switch kind {
+		case opSelect:
+			o.n = rng.Intn(1<<16) - 1
+		case opNearest:
+			o.n = rng.Intn(5)
+		case opAscend, opAscendGreaterOrEqual, opAscendLessThan, opAscendRange,
+			opDescend, opDescendGreaterThan, opDescendLessOrEqual, opDescendRange,
+			opIterate, opAscendMutable, opAll, opBackward, opRange, opEnumerate:
+			if rng.Intn(2) == 0 {
+				o.n = rng.Intn(8) + 1
+			}
+		}
+		if kind == opIterate {
+			o.opts = orderstat.IterOptions{
+				Lower:     orderstat.Bound{Item: o.a, Kind: orderstat.BoundKind(rng.Intn(3))},
+				Upper:     orderstat.Bound{Item: o.b, Kind: orderstat.BoundKind(rng.Intn(3))},
+				Direction: orderstat.Direction(rng.Intn(2)),
+				Limit:     rng.Intn(4),
+			}
+		}
+		ops[i] = o
+	}
+	return ops
+}
+
+// execute applies ops to a new tree and model, returning the index of the
+// first operation after which they disagree along with a description of the
+// disagreement. If they never disagree, it returns len(ops) and nil.
+func execute(cfg *Config, ops []op) (step int, err error) {
+	tr := cfg.NewTree()
+	m := model{less: cfg.Less}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("step %d: %v: panic: %v", step, ops[step], r)
+		}
+	}()
+	for step = range ops {
+		if err := apply(cfg, tr, &m, ops[step]); err != nil {
+			return step, fmt.Errorf("step %d: %v: %v", step, ops[step], err)
+		}
+		if got, exp := tr.Len(), len(m.items); got != exp {
+			return step, fmt.Errorf("step %d: %v: Len() = %d, expected %d", step, ops[step], got, exp)
+		}
+		if err := tr.Validate(); err != nil {
+			return step, fmt.Errorf("step %d: %v: invalid tree: %v", step, ops[step], err)
+		}
+	}
+	return len(ops), nil
+}
+
+func apply(cfg *Config, tr *orderstat.Tree, m *model, o op) error {
+	checkItem := func(got, exp orderstat.Item) error {
+		if !cfg.same(got, exp) {
+			return fmt.Errorf("got %v, expected %v", got, exp)
+		}
+		return nil
+	}
+	checkRanked := func(got orderstat.Item, gotRank int, exp int) error {
+		expItem := m.at(exp)
+		if exp < 0 || exp >= len(m.items) {
+			exp = -1
+		}
+		if !cfg.same(got, expItem) || gotRank != exp {
+			return fmt.Errorf("got (%v, %d), expected (%v, %d)", got, gotRank, expItem, exp)
+		}
+		return nil
+	}
+	switch o.kind {
+	case opReplaceOrInsert:
+		return checkItem(tr.ReplaceOrInsert(o.a), m.replaceOrInsert(o.a))
+	case opDelete:
+		return checkItem(tr.Delete(o.a), m.delete(o.a))
+	case opDeleteMin:
+		var exp orderstat.Item
+		if len(m.items) > 0 {
+			exp = m.delete(m.items[0])
+		}
+		return checkItem(tr.DeleteMin(), exp)
+	case opDeleteMax:
+		var exp orderstat.Item
+		if len(m.items) > 0 {
+			exp = m.delete(m.items[len(m.items)-1])
+		}
+		return checkItem(tr.DeleteMax(), exp)
+	case opDeleteIf:
+		pred := func(item orderstat.Item) bool {
+			return !m.less(item, o.a) && m.less(item, o.b)
+		}
+		exp := m.deleteIf(pred)
+		if got := tr.DeleteIf(pred); got != exp {
+			return fmt.Errorf("removed %d, expected %d", got, exp)
+		}
+	case opGet:
+		i, ok := m.search(o.a)
+		var exp orderstat.Item
+		if ok {
+			exp = m.items[i]
+		}
+		return checkItem(tr.Get(o.a), exp)
+	case opHas:
+		_, exp := m.search(o.a)
+		if got := tr.Has(o.a); got != exp {
+			return fmt.Errorf("got %v, expected %v", got, exp)
+		}
+	case opMin:
+		return checkItem(tr.Min(), m.at(0))
+	case opMax:
+		return checkItem(tr.Max(), m.at(len(m.items)-1))
+	case opRank:
+		i, ok := m.search(o.a)
+		if !ok {
+			i = -1
+		}
+		if got := tr.Rank(o.a); got != i {
+			return fmt.Errorf("got %d, expected %d", got, i)
+		}
+	case opSelect:
+		return checkItem(tr.Select(o.n), m.at(o.n))
+	case opFloor:
+		item, rank := tr.Floor(o.a)
+		i, ok := m.search(o.a)
+		if !ok {
+			i--
+		}
+		return checkRanked(item, rank, i)
+	case opCeiling:
+		item, rank := tr.Ceiling(o.a)
+		i, _ := m.search(o.a)
+		return checkRanked(item, rank, i)
+	case opLower:
+		item, rank := tr.Lower(o.a)
+		i, _ := m.search(o.a)
+		return checkRanked(item, rank, i-1)
+	case opHigher:
+		item, rank := tr.Higher(o.a)
+		i, ok := m.search(o.a)
+		if ok {
+			i++
+		}
+		return checkRanked(item, rank, i)
+	case opNearest:
+		i, ok := m.search(o.a)
+		// Place an absent item o.n quarters of the way from the item before
+		// it to the item after it so that the distances vary.
+		pos := func(item orderstat.Item) float64 {
+			j, ok := m.search(item)
+			if ok {
+				return float64(j)
+			}
+			return float64(j-1) + float64(o.n)/4
+		}
+		dist := func(a, b orderstat.Item) float64 {
+			return math.Abs(pos(a) - pos(b))
+		}
+		item, rank := tr.Nearest(o.a, dist)
+		switch {
+		case ok || i == 0:
+		case i == len(m.items) || dist(o.a, m.items[i-1]) <= dist(o.a, m.items[i]):
+			i--
+		}
+		return checkRanked(item, rank, i)
+	case opAscendMutable:
+		return checkAscendMutable(cfg, tr, m, o)
+	default:
+		return checkIteration(cfg, tr, m, o)
+	}
+	return nil
+}
+
+func checkIteration(cfg *Config, tr *orderstat.Tree, m *model, o op) error {
+	var got []orderstat.Item
+	f := func(item orderstat.Item) bool {
+		got = append(got, item)
+		return o.n == 0 || len(got) < o.n
+	}
+	var opts orderstat.IterOptions
+	descending := orderstat.IterOptions{Direction: orderstat.Descending}
+	switch o.kind {
+	case opAscend:
+		tr.Ascend(f)
+	case opAscendGreaterOrEqual:
+		opts.Lower = orderstat.InclusiveBound(o.a)
+		tr.AscendGreaterOrEqual(o.a, f)
+	case opAscendLessThan:
+		opts.Upper = orderstat.ExclusiveBound(o.a)
+		tr.AscendLessThan(o.a, f)
+	case opAscendRange:
+		opts.Lower = orderstat.InclusiveBound(o.a)
+		opts.Upper = orderstat.ExclusiveBound(o.b)
+		tr.AscendRange(o.a, o.b, f)
+	case opDescend:
+		opts = descending
+		tr.Descend(f)
+	case opDescendGreaterThan:
+		opts = descending
+		opts.Lower = orderstat.ExclusiveBound(o.a)
+		tr.DescendGreaterThan(o.a, f)
+	case opDescendLessOrEqual:
+		opts = descending
+		opts.Upper = orderstat.InclusiveBound(o.a)
+		tr.DescendLessOrEqual(o.a, f)
+	case opDescendRange:
+		opts = descending
+		opts.Upper = orderstat.InclusiveBound(o.a)
+		opts.Lower = orderstat.ExclusiveBound(o.b)
+		tr.DescendRange(o.a, o.b, f)
+	case opIterate:
+		opts = o.opts
+		tr.Iterate(opts, f)
+	case opAll:
+		for item := range tr.All() {
+			if !f(item) {
+				break
+			}
+		}
+	case opBackward:
+		opts = descending
+		for item := range tr.Backward() {
+			if !f(item) {
+				break
+			}
+		}
+	case opRange:
+		opts.Lower = orderstat.InclusiveBound(o.a)
+		opts.Upper = orderstat.ExclusiveBound(o.b)
+		for item := range tr.Range(o.a, o.b) {
+			if !f(item) {
+				break
+			}
+		}
+	case opEnumerate:
+		for rank, item := range tr.Enumerate() {
+			if rank != len(got) {
+				return fmt.Errorf("yielded %v with rank %d, expected %d", item, rank, len(got))
+			}
+			if !f(item) {
+				break
+			}
+		}
+	default:
+		panic(fmt.Sprintf("unknown op kind %d", o.kind))
+	}
+	return checkVisited(cfg, got, m.iterate(opts, o.n))
+}
+
+// checkAscendMutable runs AscendMutable with an iterator which inserts o.a
+// after visiting the first item and deletes every visited item less than o.b.
+// The model visits each item and then moves to the smallest item greater
+// than it, as AscendMutable promises.
+func checkAscendMutable(cfg *Config, tr *orderstat.Tree, m *model, o op) error {
+	visit := func(item orderstat.Item, i int, insert, del func(orderstat.Item)) bool {
+		if i == 0 {
+			insert(o.a)
+		}
+		if m.less(item, o.b) {
+			del(item)
+		}
+		return o.n == 0 || i+1 < o.n
+	}
+	var got []orderstat.Item
+	tr.AscendMutable(func(item orderstat.Item) bool {
+		got = append(got, item)
+		return visit(item, len(got)-1,
+			func(x orderstat.Item) { tr.ReplaceOrInsert(x) },
+			func(x orderstat.Item) { tr.Delete(x) })
+	})
+	var exp []orderstat.Item
+	for item := m.at(0); item != nil; {
+		exp = append(exp, item)
+		if !visit(item, len(exp)-1,
+			func(x orderstat.Item) { m.replaceOrInsert(x) },
+			func(x orderstat.Item) { m.delete(x) }) {
+			break
+		}
+		i, ok := m.search(item)
+		if ok {
+			i++
+		}
+		item = m.at(i)
+	}
+	return checkVisited(cfg, got, exp)
+}
+
+func checkVisited(cfg *Config, got, exp []orderstat.Item) error {
+	if len(got) != len(exp) {
+		return fmt.Errorf("visited %v, expected %v", got, exp)
+	}
+	for i := range got {
+		if !cfg.same(got[i], exp[i]) {
+			return fmt.Errorf("visited %v, expected %v", got, exp)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+// Package orderstattest provides a model based randomized test harness for
+// orderstat.Tree.
+//
+// Run generates a random sequence of operations covering the public API of
+// Tree, applies each one to both a Tree and a reference model backed by a
+// sorted slice, and fails the test as soon as the two disagree or the tree's
+// invariants are violated. Failing sequences are shrunk to a minimal
+// reproduction which is reported along with the seed used to generate them.
+//
+// The harness is parameterized by the Item type, so it can be used to test the
+// tree with custom Item implementations:
+//
+//	func TestMyItems(t *testing.T) {
+//		orderstattest.Run(t, orderstattest.Config{
+//			Gen: func(rng *rand.Rand) orderstat.Item {
+//				return myItem{key: rng.Intn(1000)}
+//			},
+//		})
+//	}
+package orderstattest
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ajwerner/orderstat"
+)
+
+// Config configures Run.
+type Config struct {
+	// Gen returns a random item. It must be provided. Generating items from a
+	// small key space produces more interesting sequences, since more
+	// operations will refer to items already in the tree.
+	Gen func(rng *rand.Rand) orderstat.Item
+
+	// NewTree constructs the tree under test. Defaults to orderstat.NewTree.
+	NewTree func() *orderstat.Tree
+
+	// Less is the order used by the tree under test. It must be provided if
+	// NewTree constructs a tree with orderstat.NewTreeWithLess. Defaults to
+	// Item.Less.
+	Less func(a, b orderstat.Item) bool
+
+	// Equal reports whether two items which compare equal under Less are
+	// identical. It is used to check that the most recently inserted item is
+	// the one stored. Defaults to reflect.DeepEqual.
+	Equal func(a, b orderstat.Item) bool
+
+	// Seed seeds the generation of operations. If zero, a seed is chosen from
+	// the current time. The seed is reported on failure so that the failing
+	// run can be reproduced.
+	Seed int64
+
+	// Runs is the number of independent sequences to execute. Defaults to 10.
+	Runs int
+
+	// Steps is the number of operations in each sequence. Defaults to 1000.
+	Steps int
+}
+
+func (cfg *Config) setDefaults() {
+	if cfg.Gen == nil {
+		panic("orderstattest: Config.Gen must be provided")
+	}
+	if cfg.NewTree == nil {
+		cfg.NewTree = orderstat.NewTree
+	}
+	if cfg.Less == nil {
+		cfg.Less = func(a, b orderstat.Item) bool { return a.Less(b) }
+	}
+	if cfg.Equal == nil {
+		cfg.Equal = func(a, b orderstat.Item) bool { return reflect.DeepEqual(a, b) }
+	}
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
+	}
+	if cfg.Runs <= 0 {
+		cfg.Runs = 10
+	}
+	if cfg.Steps <= 0 {
+		cfg.Steps = 1000
+	}
+}
+
+// same reports whether got and exp are both nil or are identical items.
+func (cfg *Config) same(got, exp orderstat.Item) bool {
+	if got == nil || exp == nil {
+		return got == nil && exp == nil
+	}
+	return cfg.Equal(got, exp)
+}
+
+// Run executes cfg.Runs random operation sequences against a tree and the
+// reference model, failing t with a shrunk reproduction if they disagree.
+func Run(t testing.TB, cfg Config) {
+	t.Helper()
+	cfg.setDefaults()
+	for run := 0; run < cfg.Runs; run++ {
+		seed := cfg.Seed + int64(run)
+		ops := generate(rand.New(rand.NewSource(seed)), cfg.Gen, cfg.Steps)
+		step, err := execute(&cfg, ops)
+		if err == nil {
+			continue
+		}
+		ops = shrink(ops[:step+1], func(ops []op) bool {
+			_, err := execute(&cfg, ops)
+			return err != nil
+		})
+		_, err = execute(&cfg, ops)
+		t.Fatalf("orderstattest: seed %d: %v\nminimal failing sequence of %d operations:\n%s",
+			seed, err, len(ops), formatOps(ops))
+	}
+}
+
+// shrink returns a subsequence of ops for which fails still returns true,
+// removing chunks of operations of decreasing size until no single operation
+// can be removed. fails(ops) must be true.
+func shrink(ops []op, fails func([]op) bool) []op {
+	for chunk := len(ops) / 2; chunk >= 1; chunk /= 2 {
+		for i := 0; i+chunk <= len(ops); {
+			candidate := make([]op, 0, len(ops)-chunk)
+			candidate = append(candidate, ops[:i]...)
+			candidate = append(candidate, ops[i+chunk:]...)
+			if fails(candidate) {
+				ops = candidate
+			} else {
+				i += chunk
+			}
+		}
+	}
+	return ops
+}
+
+func formatOps(ops []op) string {
+	var b strings.Builder
+	for i, o := range ops {
+		fmt.Fprintf(&b, "\t%d: %v\n", i, o)
+	}
+	return b.String()
+}
@@ -0,0 +1,151 @@
+package orderstattest
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/ajwerner/orderstat"
+	"github.com/stretchr/testify/assert"
+)
+
+type intItem int
+
+func (i intItem) Less(other orderstat.Item) bool { return i < other.(intItem) }
+
+type keyValue struct {
+	k int
+	v string
+}
+
+func (kv keyValue) Less(other orderstat.Item) bool { return kv.k < other.(keyValue).k }
+
+func TestRunInts(t *testing.T) {
+	Run(t, Config{
+		Seed: 1,
+		Gen: func(rng *rand.Rand) orderstat.Item {
+			return intItem(rng.Intn(64))
+		},
+	})
+}
+
+func TestRunKeyValues(t *testing.T) {
+	Run(t, Config{
+		Seed:  1,
+		Steps: 2000,
+		Gen: func(rng *rand.Rand) orderstat.Item {
+			return keyValue{k: rng.Intn(256), v: fmt.Sprint(rng.Intn(4))}
+		},
+	})
+}
+
+func TestRunWithLess(t *testing.T) {
+	// Order by value and then by descending key.
+	less := func(a, b orderstat.Item) bool {
+		ak, bk := a.(keyValue), b.(keyValue)
+		if ak.v != bk.v {
+			return ak.v < bk.v
+		}
+		return ak.k > bk.k
+	}
+	Run(t, Config{
+		Seed: 1,
+		Less: less,
+		NewTree: func() *orderstat.Tree {
+			return orderstat.NewTreeWithLess(less)
+		},
+		Gen: func(rng *rand.Rand) orderstat.Item {
+			return keyValue{k: rng.Intn(32), v: fmt.Sprint(rng.Intn(4))}
+		},
+	})
+}
+
+// recorder is a testing.TB which records failures rather than failing.
+type recorder struct {
+	testing.TB
+	failed string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.failed = fmt.Sprintf(format, args...)
+}
+
+func TestRunReportsShrunkFailure(t *testing.T) {
+	// Equal claims that items which were inserted with a value of "x" are
+	// never equal, so any sequence which stores such an item and then reads it
+	// back fails. The minimal such sequence has two operations.
+	var r recorder
+	Run(&r, Config{
+		Seed: 1,
+		Gen: func(rng *rand.Rand) orderstat.Item {
+			return keyValue{k: rng.Intn(16), v: []string{"x", "y"}[rng.Intn(2)]}
+		},
+		Equal: func(a, b orderstat.Item) bool {
+			return a.(keyValue).v != "x" && a == b
+		},
+	})
+	assert.Contains(t, r.failed, "seed 1")
+	assert.Contains(t, r.failed, "minimal failing sequence of 2 operations")
+	assert.Equal(t, 4, strings.Count(r.failed, "\n"), r.failed)
+}
+
+func TestShrink(t *testing.T) {
+	var ops []op
+	for i := 0; i < 100; i++ {
+		ops = append(ops, op{n: i})
+	}
+	// Fails whenever the sequence contains both 17 and 42.
+	fails := func(ops []op) bool {
+		var a, b bool
+		for _, o := range ops {
+			a = a || o.n == 17
+			b = b || o.n == 42
+		}
+		return a && b
+	}
+	assert.Equal(t, []op{{n: 17}, {n: 42}}, shrink(ops, fails))
+}
+
+func TestGenerateCoversEveryOp(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var seen [numOpKinds]bool
+	for _, o := range generate(rng, func(rng *rand.Rand) orderstat.Item {
+		return intItem(rng.Intn(64))
+	}, 10000) {
+		seen[o.kind] = true
+	}
+	for k, ok := range seen {
+		assert.True(t, ok, opNames[k])
+		assert.NotEmpty(t, opNames[k], "op kind %d has no name", k)
+	}
+}
+
+func TestApplyDetectsDivergence(t *testing.T) {
+	// The tree holds an item which the model lacks, so every operation which
+	// reaches it must report a disagreement.
+	cfg := Config{Gen: func(*rand.Rand) orderstat.Item { return nil }}
+	cfg.setDefaults()
+	for _, o := range []op{
+		{kind: opNearest, a: intItem(9), n: 2},
+		{kind: opAscendMutable, a: intItem(0), b: intItem(0)},
+		{kind: opAll},
+		{kind: opBackward, n: 1},
+		{kind: opRange, a: intItem(5), b: intItem(100)},
+		{kind: opEnumerate},
+	} {
+		tr := orderstat.NewTree()
+		m := model{less: cfg.Less}
+		for i := 0; i < 10; i++ {
+			tr.ReplaceOrInsert(intItem(i))
+			if i != 9 {
+				m.replaceOrInsert(intItem(i))
+			}
+		}
+		assert.Error(t, apply(&cfg, tr, &m, o), o.String())
+		m.replaceOrInsert(intItem(9))
+		assert.NoError(t, apply(&cfg, tr, &m, o), o.String())
+	}
+}
@@ -0,0 +1,430 @@
+package orderstat
+
+////////////////////////////////////////////////////////////////////////////////
+// PersistentTree
+////////////////////////////////////////////////////////////////////////////////
+
+// PersistentTree is a fully persistent order statistic tree.
+//
+// Insert and Delete never modify the receiver. Instead they return a new
+// PersistentTree which shares all untouched nodes with the receiver, copying
+// only the O(log n) nodes along the modified path. Every earlier version
+// therefore remains valid and may be queried with Rank, Select and friends
+// at any time.
+//
+// The zero value is an empty tree at version 0 which orders items the same
+// way as a Tree created with NewTree. PersistentTree values are safe for
+// concurrent use by multiple goroutines.
+type PersistentTree struct {
+	root    *pnode
+	version int
+	// cmp is derived from the less passed to NewPersistentTreeWithLess, or nil
+	// to use itemCompare.
+	cmp func(a, b Item) int
+}
+
+// NewPersistentTreeWithLess returns an empty PersistentTree which orders
+// items using less rather than Item.Less, in the same way as a Tree created
+// with NewTreeWithLess. Every version derived from it uses the same order.
+func NewPersistentTreeWithLess(less func(a, b Item) bool) PersistentTree {
+	return PersistentTree{cmp: lessCompare(less)}
+}
+
+// compare returns the comparison used to order the tree.
+func (t PersistentTree) compare() func(a, b Item) int {
+	if t.cmp == nil {
+		return itemCompare
+	}
+	return t.cmp
+}
+
+// Version returns the number of mutations which have been applied to the empty
+// tree in order to produce t.
+func (t PersistentTree) Version() int {
+	return t.version
+}
+
+// Len returns the number of items in the tree.
+func (t PersistentTree) Len() int {
+	return int(t.root.size())
+}
+
+// Insert returns a new version of the tree containing item. If an item equal
+// to the passed in item already exists, it is replaced in the new version.
+func (t PersistentTree) Insert(item Item) PersistentTree {
+	root := t.root.insert(t.compare(), item)
+	root.red = false
+	return PersistentTree{root: root, version: t.version + 1, cmp: t.cmp}
+}
+
+// Delete returns a new version of the tree which does not contain an item
+// equal to the passed in item. If no such item exists, t is returned unchanged.
+func (t PersistentTree) Delete(item Item) PersistentTree {
+	if !t.Has(item) {
+		return t
+	}
+	root := t.root.clone()
+	if !root.left.isRed() && !root.right.isRed() {
+		root.red = true
+	}
+	root = root.del(t.compare(), item)
+	if root != nil {
+		root.red = false
+	}
+	return PersistentTree{root: root, version: t.version + 1, cmp: t.cmp}
+}
+
+// DeleteMin returns a new version of the tree with the smallest item removed.
+// If the tree is empty, t is returned unchanged.
+func (t PersistentTree) DeleteMin() PersistentTree {
+	if t.root == nil {
+		return t
+	}
+	root := t.root.clone()
+	if !root.left.isRed() && !root.right.isRed() {
+		root.red = true
+	}
+	root, _ = root.delMin()
+	if root != nil {
+		root.red = false
+	}
+	return PersistentTree{root: root, version: t.version + 1, cmp: t.cmp}
+}
+
+// DeleteMax returns a new version of the tree with the largest item removed.
+// If the tree is empty, t is returned unchanged.
+func (t PersistentTree) DeleteMax() PersistentTree {
+	if t.root == nil {
+		return t
+	}
+	return t.Delete(t.Max())
+}
+
+// Get looks for the key item in the tree, returning it. It returns nil if
+// unable to find that item.
+func (t PersistentTree) Get(key Item) Item {
+	if n := t.root.find(t.compare(), key); n != nil {
+		return n.item
+	}
+	return nil
+}
+
+// Has returns true if the tree contains an item equal to key.
+func (t PersistentTree) Has(key Item) bool {
+	return t.root.find(t.compare(), key) != nil
+}
+
+// Min returns the smallest item in the tree, or nil if the tree is empty.
+func (t PersistentTree) Min() Item {
+	n := t.root
+	if n == nil {
+		return nil
+	}
+	for n.left != nil {
+		n = n.left
+	}
+	return n.item
+}
+
+// Max returns the largest item in the tree, or nil if the tree is empty.
+func (t PersistentTree) Max() Item {
+	n := t.root
+	if n == nil {
+		return nil
+	}
+	for n.right != nil {
+		n = n.right
+	}
+	return n.item
+}
+
+// Select returns the item with rank i, that is the i-th smallest item in the
+// tree. It returns nil if i is out of range.
+func (t PersistentTree) Select(i int) Item {
+	if i < 0 || i >= t.Len() {
+		return nil
+	}
+	rank := uint32(i)
+	n := t.root
+	for {
+		lc := n.left.size()
+		switch {
+		case rank < lc:
+			n = n.left
+		case rank > lc:
+			rank -= lc + 1
+			n = n.right
+		default:
+			return n.item
+		}
+	}
+}
+
+// Rank returns the number of items in the tree which are less than item if
+// an item equal to item exists in the tree, or -1 otherwise.
+func (t PersistentTree) Rank(item Item) int {
+	var rank uint32
+	cmp := t.compare()
+	for n := t.root; n != nil; {
+		switch c := cmp(item, n.item); {
+		case c < 0:
+			n = n.left
+		case c > 0:
+			rank += n.left.size() + 1
+			n = n.right
+		default:
+			return int(rank + n.left.size())
+		}
+	}
+	return -1
+}
+
+// Ascend calls the iterator for every value in the tree within the range
+// [first, last], until iterator returns false.
+func (t PersistentTree) Ascend(f ItemIterator) {
+	t.root.ascend(f)
+}
+
+// Descend calls the iterator for every value in the tree within the range
+// [last, first], until iterator returns false.
+func (t PersistentTree) Descend(f ItemIterator) {
+	t.root.descend(f)
+}
+
+// History records every version of a PersistentTree produced by a sequence of
+// mutations so that earlier versions may be looked up by number.
+//
+// The zero value is a History holding only the empty version 0 of a tree
+// ordered by Item.Less.
+type History struct {
+	empty    PersistentTree
+	versions []PersistentTree
+}
+
+// NewHistoryWithLess returns a History of a PersistentTree which orders items
+// using less. See NewPersistentTreeWithLess.
+func NewHistoryWithLess(less func(a, b Item) bool) *History {
+	return &History{empty: NewPersistentTreeWithLess(less)}
+}
+
+// Latest returns the most recent version of the tree.
+func (h *History) Latest() PersistentTree {
+	if len(h.versions) == 0 {
+		return h.empty
+	}
+	return h.versions[len(h.versions)-1]
+}
+
+// At returns the tree as of the given version. The second return value is
+// false if no such version has been recorded.
+func (h *History) At(version int) (PersistentTree, bool) {
+	if version == 0 {
+		return h.empty, true
+	}
+	if version < 0 || version > len(h.versions) {
+		return PersistentTree{}, false
+	}
+	return h.versions[version-1], true
+}
+
+// Insert applies Insert to the latest version and records the result.
+func (h *History) Insert(item Item) PersistentTree {
+	return h.record(h.Latest().Insert(item))
+}
+
+// Delete applies Delete to the latest version and records the result. If the
+// item does not exist, no new version is recorded.
+func (h *History) Delete(item Item) PersistentTree {
+	return h.record(h.Latest().Delete(item))
+}
+
+func (h *History) record(t PersistentTree) PersistentTree {
+	if t.version > len(h.versions) {
+		h.versions = append(h.versions, t)
+	}
+	return t
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// pnode
+////////////////////////////////////////////////////////////////////////////////
+
+// pnode is an immutable node of a PersistentTree. A pnode which is reachable
+// from a published PersistentTree must never be modified; all of the methods
+// below which mutate their receiver must only be called on a fresh clone.
+type pnode struct {
+	item        Item
+	left, right *pnode
+	count       uint32
+	red         bool
+}
+
+func (n *pnode) clone() *pnode {
+	c := *n
+	return &c
+}
+
+func (n *pnode) size() uint32 {
+	if n == nil {
+		return 0
+	}
+	return n.count
+}
+
+func (n *pnode) isRed() bool {
+	return n != nil && n.red
+}
+
+func (n *pnode) find(cmp func(a, b Item) int, key Item) *pnode {
+	for n != nil {
+		switch c := cmp(key, n.item); {
+		case c < 0:
+			n = n.left
+		case c > 0:
+			n = n.right
+		default:
+			return n
+		}
+	}
+	return nil
+}
+
+func (n *pnode) ascend(f ItemIterator) bool {
+	if n == nil {
+		return true
+	}
+	return n.left.ascend(f) && f(n.item) && n.right.ascend(f)
+}
+
+func (n *pnode) descend(f ItemIterator) bool {
+	if n == nil {
+		return true
+	}
+	return n.right.descend(f) && f(n.item) && n.left.descend(f)
+}
+
+func (n *pnode) insert(cmp func(a, b Item) int, item Item) *pnode {
+	if n == nil {
+		return &pnode{item: item, count: 1, red: true}
+	}
+	n = n.clone()
+	switch c := cmp(item, n.item); {
+	case c < 0:
+		n.left = n.left.insert(cmp, item)
+	case c > 0:
+		n.right = n.right.insert(cmp, item)
+	default:
+		n.item = item
+		return n
+	}
+	return n.fixUp()
+}
+
+// del removes item from the subtree rooted at n. The item must be present and
+// n must already be a fresh clone.
+func (n *pnode) del(cmp func(a, b Item) int, item Item) *pnode {
+	if c := cmp(item, n.item); c < 0 {
+		if !n.left.isRed() && !n.left.left.isRed() {
+			n = n.moveRedLeft()
+		}
+		n.left = n.left.clone().del(cmp, item)
+	} else {
+		if n.left.isRed() {
+			n = n.rotateRight()
+			c = cmp(item, n.item)
+		}
+		if c == 0 && n.right == nil {
+			return nil
+		}
+		if !n.right.isRed() && !n.right.left.isRed() {
+			n = n.moveRedRight()
+			c = cmp(item, n.item)
+		}
+		if c == 0 {
+			n.right, n.item = n.right.clone().delMin()
+		} else {
+			n.right = n.right.clone().del(cmp, item)
+		}
+	}
+	return n.fixUp()
+}
+
+// delMin removes the smallest item from the subtree rooted at n, which must
+// already be a fresh clone.
+func (n *pnode) delMin() (_ *pnode, removed Item) {
+	if n.left == nil {
+		return nil, n.item
+	}
+	if !n.left.isRed() && !n.left.left.isRed() {
+		n = n.moveRedLeft()
+	}
+	n.left, removed = n.left.clone().delMin()
+	return n.fixUp(), removed
+}
+
+func (n *pnode) fixUp() *pnode {
+	if n.right.isRed() {
+		n = n.rotateLeft()
+	}
+	if n.left.isRed() && n.left.left.isRed() {
+		n = n.rotateRight()
+	}
+	if n.left.isRed() && n.right.isRed() {
+		n.colorFlip()
+	}
+	n.count = n.left.size() + n.right.size() + 1
+	return n
+}
+
+func (n *pnode) rotateLeft() *pnode {
+	x := n.right.clone()
+	n.right = x.left
+	x.left = n
+	x.red = n.red
+	n.red = true
+	n.count = n.left.size() + n.right.size() + 1
+	x.count = x.left.size() + x.right.size() + 1
+	return x
+}
+
+func (n *pnode) rotateRight() *pnode {
+	x := n.left.clone()
+	n.left = x.right
+	x.right = n
+	x.red = n.red
+	n.red = true
+	n.count = n.left.size() + n.right.size() + 1
+	x.count = x.left.size() + x.right.size() + 1
+	return x
+}
+
+func (n *pnode) colorFlip() {
+	n.red = !n.red
+	if n.left != nil {
+		n.left = n.left.clone()
+		n.left.red = !n.left.red
+	}
+	if n.right != nil {
+		n.right = n.right.clone()
+		n.right.red = !n.right.red
+	}
+}
+
+func (n *pnode) moveRedLeft() *pnode {
+	n.colorFlip()
+	if n.right.left.isRed() {
+		n.right = n.right.rotateRight()
+		n = n.rotateLeft()
+		n.colorFlip()
+	}
+	return n
+}
+
+func (n *pnode) moveRedRight() *pnode {
+	n.colorFlip()
+	if n.left.left.isRed() {
+		n = n.rotateRight()
+		n.colorFlip()
+	}
+	return n
+}
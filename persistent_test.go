@@ -0,0 +1,168 @@
+package orderstat
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func (t PersistentTree) isLLRB() error {
+	_, err := t.root.isLLRB(t.compare(), nil, nil)
+	return err
+}
+
+func (n *pnode) isLLRB(cmp func(a, b Item) int, min, max Item) (blackHeight int, err error) {
+	if n == nil {
+		return 0, nil
+	}
+	if min != nil && cmp(min, n.item) >= 0 {
+		return 0, fmt.Errorf("key %v <= min %v", n.item, min)
+	}
+	if max != nil && cmp(n.item, max) >= 0 {
+		return 0, fmt.Errorf("key %v >= max %v", n.item, max)
+	}
+	if n.right.isRed() {
+		return 0, fmt.Errorf("right leaning red link at %v", n.item)
+	}
+	if n.isRed() && n.left.isRed() {
+		return 0, fmt.Errorf("consecutive red links at %v", n.item)
+	}
+	lh, err := n.left.isLLRB(cmp, min, n.item)
+	if err != nil {
+		return 0, err
+	}
+	rh, err := n.right.isLLRB(cmp, n.item, max)
+	if err != nil {
+		return 0, err
+	}
+	if lh != rh {
+		return 0, fmt.Errorf("black height mismatch at %v: %d != %d", n.item, lh, rh)
+	}
+	if n.count != n.left.size()+n.right.size()+1 {
+		return 0, fmt.Errorf("count mismatch at %v", n.item)
+	}
+	if !n.isRed() {
+		lh++
+	}
+	return lh, nil
+}
+
+func TestPersistentTree(t *testing.T) {
+	const N = 1000
+	var h History
+	snapshots := [][]int{nil}
+	cur := map[int]bool{}
+	for i := 0; i < 4*N; i++ {
+		v := rand.Intn(N)
+		if rand.Intn(3) == 0 {
+			before := h.Latest().Version()
+			h.Delete(intItem(v))
+			if !cur[v] {
+				assert.Equal(t, before, h.Latest().Version())
+				continue
+			}
+			delete(cur, v)
+		} else {
+			h.Insert(intItem(v))
+			cur[v] = true
+		}
+		assert.Nil(t, h.Latest().isLLRB())
+		var snap []int
+		for k := range cur {
+			snap = append(snap, k)
+		}
+		sort.Ints(snap)
+		snapshots = append(snapshots, snap)
+	}
+	assert.Equal(t, len(snapshots)-1, h.Latest().Version())
+	for v, snap := range snapshots {
+		tr, ok := h.At(v)
+		assert.True(t, ok)
+		assert.Equal(t, v, tr.Version())
+		assert.Equal(t, len(snap), tr.Len())
+		for i, k := range snap {
+			assert.Equal(t, i, tr.Rank(intItem(k)))
+			assert.Equal(t, intItem(k), tr.Select(i))
+		}
+		var got []int
+		tr.Ascend(func(i Item) bool {
+			got = append(got, int(i.(intItem)))
+			return true
+		})
+		assert.Equal(t, snap, got)
+	}
+	_, ok := h.At(len(snapshots))
+	assert.False(t, ok)
+}
+
+func TestPersistentTreeDeleteMinMax(t *testing.T) {
+	var tr PersistentTree
+	for _, i := range rand.Perm(100) {
+		tr = tr.Insert(intItem(i))
+	}
+	orig := tr
+	for i := 0; i < 50; i++ {
+		assert.Equal(t, intItem(i), tr.Min())
+		assert.Equal(t, intItem(99-i), tr.Max())
+		tr = tr.DeleteMin().DeleteMax()
+		assert.Nil(t, tr.isLLRB())
+	}
+	assert.Equal(t, 0, tr.Len())
+	assert.Nil(t, tr.Min())
+	assert.Equal(t, tr, tr.DeleteMin())
+	assert.Equal(t, 100, orig.Len())
+	assert.Equal(t, intItem(42), orig.Get(intItem(42)))
+	assert.Equal(t, 42, orig.Rank(intItem(42)))
+}
+
+func TestPersistentTreeWithLess(t *testing.T) {
+	byScore := func(a, b Item) bool {
+		ap, bp := a.(player), b.(player)
+		if ap.score != bp.score {
+			return ap.score > bp.score
+		}
+		return ap.name < bp.name
+	}
+	h := NewHistoryWithLess(byScore)
+	for _, i := range rand.Perm(100) {
+		h.Insert(player{name: fmt.Sprintf("p%02d", i), score: i % 10})
+		assert.Nil(t, h.Latest().isLLRB())
+	}
+	tr := h.Latest()
+	assert.Equal(t, player{"p09", 9}, tr.Min())
+	assert.Equal(t, player{"p90", 0}, tr.Max())
+	assert.Equal(t, 74, tr.Rank(player{"p42", 2}))
+	assert.Equal(t, player{"p42", 2}, tr.Select(74))
+
+	// Equality is decided by the comparator rather than Item.Less, which
+	// compares only names.
+	assert.False(t, tr.Has(player{name: "p07"}))
+	tr = tr.Delete(player{"p07", 7}).DeleteMin()
+	assert.Nil(t, tr.isLLRB())
+	assert.Equal(t, 98, tr.Len())
+	assert.Equal(t, player{"p19", 9}, tr.Min())
+	assert.Equal(t, -1, tr.Rank(player{"p07", 7}))
+
+	// Versions derived from the empty version use the same order.
+	empty, ok := h.At(0)
+	assert.True(t, ok)
+	assert.Equal(t, player{"b", 2}, empty.Insert(player{"a", 1}).Insert(player{"b", 2}).Min())
+}
+
+func TestPersistentTreeComparer(t *testing.T) {
+	var less, compare int
+	var tr PersistentTree
+	for _, i := range rand.Perm(200) {
+		tr = tr.Insert(countingItem{v: i, less: &less, compare: &compare})
+	}
+	for i := 0; i < 200; i += 2 {
+		tr = tr.Delete(countingItem{v: i, less: &less, compare: &compare})
+	}
+	assert.Equal(t, 50, tr.Rank(countingItem{v: 101, less: &less, compare: &compare}))
+	assert.Nil(t, tr.isLLRB())
+	assert.Equal(t, 0, less)
+	assert.NotZero(t, compare)
+}
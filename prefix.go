@@ -0,0 +1,130 @@
+package orderstat
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// Prefix
+////////////////////////////////////////////////////////////////////////////////
+
+// Keyed is implemented by items which are ordered by a byte string key.
+//
+// Trees holding Keyed or StringKeyed items support AscendPrefix and
+// CountPrefix. The tree's order must agree with the lexicographic order of
+// the keys for those methods to be meaningful.
+type Keyed interface {
+	Item
+
+	// Key returns the key of the item. It must not be modified.
+	Key() []byte
+}
+
+// StringKeyed is implemented by items which are ordered by a string key. It
+// behaves exactly like Keyed.
+type StringKeyed interface {
+	Item
+
+	// Key returns the key of the item.
+	Key() string
+}
+
+// AscendPrefix calls the iterator for every item in the tree whose key begins
+// with prefix in ascending order, until iterator returns false. Every item in
+// the tree must implement Keyed or StringKeyed.
+func (t *Tree) AscendPrefix(prefix []byte, f ItemIterator) {
+	p := newKeyBound(prefix)
+	var it iterator
+	for ok := it.seekKey(t, p); ok && p.isPrefixOf(it.item) && f(it.item); {
+		it, ok = it.next(t)
+	}
+}
+
+// CountPrefix returns the number of items in the tree whose key begins with
+// prefix. It runs in O(log n) time by subtracting the ranks of the bounds of
+// the prefix range rather than visiting the matching items. Every item in the
+// tree must implement Keyed or StringKeyed.
+func (t *Tree) CountPrefix(prefix []byte) int {
+	end := t.Len()
+	if limit := prefixEnd(prefix); limit != nil {
+		end = t.countKeyLess(newKeyBound(limit))
+	}
+	return end - t.countKeyLess(newKeyBound(prefix))
+}
+
+// prefixEnd returns the smallest key which is greater than every key which
+// begins with prefix, or nil if no such key exists because prefix is empty or
+// consists only of 0xff bytes.
+func prefixEnd(prefix []byte) []byte {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] != 0xff {
+			end := append([]byte(nil), prefix[:i+1]...)
+			end[i]++
+			return end
+		}
+	}
+	return nil
+}
+
+// keyBound holds a key in both forms so that it can be compared against
+// Keyed and StringKeyed items without conversions.
+type keyBound struct {
+	b []byte
+	s string
+}
+
+func newKeyBound(key []byte) keyBound {
+	return keyBound{b: key, s: string(key)}
+}
+
+// compare compares the key of item with k.
+func (k keyBound) compare(item Item) int {
+	switch item := item.(type) {
+	case Keyed:
+		return bytes.Compare(item.Key(), k.b)
+	case StringKeyed:
+		return strings.Compare(item.Key(), k.s)
+	}
+	panic(fmt.Sprintf("orderstat: %T implements neither Keyed nor StringKeyed", item))
+}
+
+func (k keyBound) isPrefixOf(item Item) bool {
+	switch item := item.(type) {
+	case Keyed:
+		return bytes.HasPrefix(item.Key(), k.b)
+	case StringKeyed:
+		return strings.HasPrefix(item.Key(), k.s)
+	}
+	panic(fmt.Sprintf("orderstat: %T implements neither Keyed nor StringKeyed", item))
+}
+
+// countKeyLess returns the number of items in the tree whose key is less than
+// key.
+func (t *Tree) countKeyLess(key keyBound) int {
+	var rank uint32
+	for it := t.root; it.node != nil; {
+		if key.compare(it.item) < 0 {
+			rank += it.l(t).count() + 1
+			it = it.r(t)
+		} else {
+			it = it.l(t)
+		}
+	}
+	return int(rank)
+}
+
+// seekKey positions it at the first item whose key is greater than or equal
+// to key. It returns false if there is no such item.
+func (it *iterator) seekKey(t *Tree, key keyBound) (ok bool) {
+	for cur := t.root; cur.node != nil; {
+		if key.compare(cur.item) < 0 {
+			cur = cur.r(t)
+		} else {
+			*it, ok = cur, true
+			cur = cur.l(t)
+		}
+	}
+	return ok
+}
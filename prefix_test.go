@@ -0,0 +1,77 @@
+package orderstat
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type word string
+
+func (w word) Less(other Item) bool { return w < other.(word) }
+func (w word) Key() string          { return string(w) }
+
+type bytesKey []byte
+
+func (b bytesKey) Less(other Item) bool { return bytes.Compare(b, other.(bytesKey)) < 0 }
+func (b bytesKey) Key() []byte          { return b }
+
+func TestPrefix(t *testing.T) {
+	keys := []string{
+		"", "a", "a\x00", "ab", "abc", "abd", "ac", "b", "foo", "food", "fool",
+		"fop", "\xff", "\xff\x00", "\xff\xff", "\xff\xff\xff", "a\xff", "a\xff\xff",
+	}
+	sort.Strings(keys)
+	words, byteKeys := NewTree(), NewTree()
+	for _, k := range keys {
+		words.ReplaceOrInsert(word(k))
+		byteKeys.ReplaceOrInsert(bytesKey(k))
+	}
+	prefixes := []string{
+		"", "a", "ab", "abc", "abe", "a\xff", "\xff", "\xff\xff", "\xff\xff\xff\xff",
+		"c", "fo", "foo", "fooo", "zzz",
+	}
+	for _, prefix := range prefixes {
+		var want []string
+		for _, k := range keys {
+			if strings.HasPrefix(k, prefix) {
+				want = append(want, k)
+			}
+		}
+		for _, tr := range []*Tree{words, byteKeys} {
+			var got []string
+			tr.AscendPrefix([]byte(prefix), func(i Item) bool {
+				switch i := i.(type) {
+				case word:
+					got = append(got, string(i))
+				case bytesKey:
+					got = append(got, string(i))
+				}
+				return true
+			})
+			assert.Equal(t, want, got, "%q", prefix)
+			assert.Equal(t, len(want), tr.CountPrefix([]byte(prefix)), "%q", prefix)
+		}
+	}
+
+	var got []Item
+	words.AscendPrefix([]byte("foo"), func(i Item) bool {
+		got = append(got, i)
+		return len(got) < 2
+	})
+	assert.Equal(t, []Item{word("foo"), word("food")}, got)
+
+	assert.Equal(t, 0, NewTree().CountPrefix([]byte("a")))
+	NewTree().AscendPrefix(nil, func(Item) bool {
+		t.Fatal("unexpected item")
+		return true
+	})
+	assert.Panics(t, func() {
+		tr := NewTree()
+		tr.ReplaceOrInsert(intItem(1))
+		tr.CountPrefix([]byte("a"))
+	})
+}
@@ -0,0 +1,74 @@
+package orderstat
+
+import (
+	"math/rand"
+	"slices"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// Sampling
+////////////////////////////////////////////////////////////////////////////////
+
+// Random returns an item chosen uniformly at random from the tree using rng,
+// or nil if the tree is empty. It takes O(log n) time.
+func (t *Tree) Random(rng *rand.Rand) Item {
+	n := t.Len()
+	if n == 0 {
+		return nil
+	}
+	it, _ := t.at(rng.Intn(n))
+	return it.item
+}
+
+// SampleN returns n distinct items chosen uniformly at random from the tree
+// using rng, in ascending order. If the tree holds no more than n items,
+// every item is returned. It takes O(n log n) time in the size of the
+// sample, independent of the size of the tree.
+func (t *Tree) SampleN(n int, rng *rand.Rand) []Item {
+	size := t.Len()
+	if n >= size {
+		return t.BottomK(size)
+	}
+	if n <= 0 {
+		return nil
+	}
+	// Choose n distinct ranks using Floyd's algorithm, which makes exactly n
+	// calls to rng.
+	chosen := make(map[int]struct{}, n)
+	ranks := make([]int, 0, n)
+	for j := size - n; j < size; j++ {
+		r := rng.Intn(j + 1)
+		if _, ok := chosen[r]; ok {
+			r = j
+		}
+		chosen[r] = struct{}{}
+		ranks = append(ranks, r)
+	}
+	slices.Sort(ranks)
+	items := make([]Item, n)
+	for i, r := range ranks {
+		items[i] = t.Select(r)
+	}
+	return items
+}
+
+// RandomInRange returns an item chosen uniformly at random using rng from the
+// items in the tree within the range [greaterOrEqual, lessThan), or nil if
+// there are none. The range is located by the ranks of its bounds, so it
+// takes O(log n) time regardless of the number of items in the range.
+func (t *Tree) RandomInRange(greaterOrEqual, lessThan Item, rng *rand.Rand) Item {
+	lo, hi := t.countLess(greaterOrEqual), t.countLess(lessThan)
+	if lo >= hi {
+		return nil
+	}
+	it, _ := t.at(lo + rng.Intn(hi-lo))
+	return it.item
+}
+
+// countLess returns the number of items in the tree which are less than item.
+func (t *Tree) countLess(item Item) int {
+	if _, rank := t.Ceiling(item); rank >= 0 {
+		return rank
+	}
+	return t.Len()
+}
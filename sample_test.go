@@ -0,0 +1,76 @@
+package orderstat
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	tr := NewTree()
+	assert.Nil(t, tr.Random(rng))
+	const N, samples = 10, 100000
+	for i := 0; i < N; i++ {
+		tr.ReplaceOrInsert(intItem(i))
+	}
+	counts := make([]int, N)
+	for i := 0; i < samples; i++ {
+		counts[tr.Random(rng).(intItem)]++
+	}
+	for i, c := range counts {
+		assert.InDelta(t, samples/N, c, samples/N/10, "%d", i)
+	}
+}
+
+func TestSampleN(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	tr := NewTree()
+	assert.Nil(t, tr.SampleN(3, rng))
+	const N = 20
+	for i := 0; i < N; i++ {
+		tr.ReplaceOrInsert(intItem(i))
+	}
+	assert.Nil(t, tr.SampleN(0, rng))
+	assert.Equal(t, tr.BottomK(N), tr.SampleN(N, rng))
+	assert.Equal(t, tr.BottomK(N), tr.SampleN(N+5, rng))
+	counts := make([]int, N)
+	const runs = 20000
+	for i := 0; i < runs; i++ {
+		sample := tr.SampleN(5, rng)
+		assert.Len(t, sample, 5)
+		for j, item := range sample {
+			if j > 0 {
+				assert.True(t, sample[j-1].Less(item), "%v", sample)
+			}
+			counts[item.(intItem)]++
+		}
+	}
+	// Each item is in a sample of 5 of 20 a quarter of the time.
+	for i, c := range counts {
+		assert.InDelta(t, runs/4, c, runs/4/10, "%d", i)
+	}
+}
+
+func TestRandomInRange(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	tr := NewTree()
+	for i := 0; i < 100; i += 2 {
+		tr.ReplaceOrInsert(intItem(i))
+	}
+	seen := map[intItem]int{}
+	for i := 0; i < 10000; i++ {
+		item := tr.RandomInRange(intItem(9), intItem(20), rng).(intItem)
+		seen[item]++
+	}
+	assert.Len(t, seen, 5)
+	for item, c := range seen {
+		assert.True(t, item >= 10 && item <= 18, "%v", item)
+		assert.InDelta(t, 2000, c, 200, "%v", item)
+	}
+	assert.Equal(t, intItem(98), tr.RandomInRange(intItem(97), intItem(1000), rng))
+	assert.Nil(t, tr.RandomInRange(intItem(11), intItem(12), rng))
+	assert.Nil(t, tr.RandomInRange(intItem(20), intItem(10), rng))
+	assert.Nil(t, tr.RandomInRange(intItem(100), intItem(200), rng))
+}
@@ -0,0 +1,516 @@
+package orderstat
+
+import (
+	"fmt"
+	"iter"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// Sequence
+////////////////////////////////////////////////////////////////////////////////
+
+// Sequence is an indexable list of items, sometimes called a rope, which
+// supports insertion and removal at any position as well as concatenation and
+// splitting in O(log n) time.
+//
+// A Sequence uses the same left-leaning red-black balancing and subtree counts
+// as Tree, but the position of each item is implied by the number of items
+// before it rather than by comparing items, so items need not be ordered and
+// Item.Less is never called. Any Item may be stored, including nil.
+//
+// Unlike Tree, a Sequence allocates each node separately rather than in an
+// arena, so that SplitAt and Concat can move whole subtrees between
+// Sequences without copying them. Each node belongs to exactly one Sequence,
+// so distinct Sequences may be used concurrently, and the nodes of a
+// Sequence which is dropped are reclaimed by the garbage collector.
+//
+// The zero value is an empty Sequence.
+type Sequence struct {
+	root *snode
+}
+
+// NewSequence creates a new Sequence holding items in order. It takes O(n)
+// time.
+func NewSequence(items ...Item) *Sequence {
+	height := 0
+	for minSize(height+1) <= len(items) {
+		height++
+	}
+	s := &Sequence{root: buildSequence(items, height)}
+	if s.root != nil {
+		s.root.red = false
+	}
+	return s
+}
+
+// Len returns the number of items in the sequence.
+func (s *Sequence) Len() int {
+	return int(s.root.size())
+}
+
+// At returns the item at position i. It panics if i is out of range.
+func (s *Sequence) At(i int) Item {
+	return s.mustAt(i).item
+}
+
+// Set replaces the item at position i and returns the item it replaced. It
+// panics if i is out of range.
+func (s *Sequence) Set(i int, item Item) (old Item) {
+	n := s.mustAt(i)
+	old, n.item = n.item, item
+	return old
+}
+
+func (s *Sequence) mustAt(i int) *snode {
+	if i < 0 || i >= s.Len() {
+		panic(indexOutOfRange(i, s.Len()))
+	}
+	return s.root.at(uint32(i))
+}
+
+// InsertAt inserts item at position i, shifting the item at i and those after
+// it back by one position. i may be Len, which appends item. InsertAt panics
+// if i is out of range.
+func (s *Sequence) InsertAt(i int, item Item) {
+	if i < 0 || i > s.Len() {
+		panic(indexOutOfRange(i, s.Len()+1))
+	}
+	s.root = s.root.insertAt(uint32(i), item)
+	s.root.red = false
+}
+
+// Append adds items to the end of the sequence.
+func (s *Sequence) Append(items ...Item) {
+	for _, item := range items {
+		s.InsertAt(s.Len(), item)
+	}
+}
+
+// RemoveAt removes and returns the item at position i, shifting the items
+// after it forward by one position. It panics if i is out of range.
+func (s *Sequence) RemoveAt(i int) (removed Item) {
+	if i < 0 || i >= s.Len() {
+		panic(indexOutOfRange(i, s.Len()))
+	}
+	if !s.root.left.isRed() && !s.root.right.isRed() {
+		s.root.red = true
+	}
+	s.root, removed = s.root.delAt(uint32(i))
+	if s.root != nil {
+		s.root.red = false
+	}
+	return removed
+}
+
+// RemoveRange removes the items at positions [i, j), shifting the items after
+// them forward by j-i positions. It takes O(log n) time regardless of the
+// number of items removed and panics if the range is invalid.
+func (s *Sequence) RemoveRange(i, j int) {
+	if i < 0 || j < i || j > s.Len() {
+		panic(sliceOutOfRange(i, j, s.Len()))
+	}
+	rest := s.SplitAt(j)
+	s.SplitAt(i)
+	s.Concat(rest)
+}
+
+// Slice returns the items at positions [i, j). It panics if the range is
+// invalid.
+func (s *Sequence) Slice(i, j int) []Item {
+	if i < 0 || j < i || j > s.Len() {
+		panic(sliceOutOfRange(i, j, s.Len()))
+	}
+	return s.root.appendRange(make([]Item, 0, j-i), uint32(i), uint32(j))
+}
+
+// All returns an iterator over the positions and items of the sequence in
+// order.
+func (s *Sequence) All() iter.Seq2[int, Item] {
+	return func(yield func(int, Item) bool) {
+		i := 0
+		s.root.ascend(func(item Item) bool {
+			if !yield(i, item) {
+				return false
+			}
+			i++
+			return true
+		})
+	}
+}
+
+// Concat moves the items of other to the end of s in O(log n) time, leaving
+// other empty. It panics if other is s.
+func (s *Sequence) Concat(other *Sequence) {
+	if other == s {
+		panic("orderstat: Concat of a Sequence with itself")
+	}
+	s.root = concat(s.root, other.root)
+	other.root = nil
+}
+
+// SplitAt removes the items at positions [i, Len) from s and returns them as
+// a new Sequence in O(log n) time. It panics if i is out of range.
+func (s *Sequence) SplitAt(i int) *Sequence {
+	if i < 0 || i > s.Len() {
+		panic(indexOutOfRange(i, s.Len()+1))
+	}
+	l, _, r, _ := s.root.split(uint32(i), s.root.blackHeight())
+	s.root = l
+	return &Sequence{root: r}
+}
+
+func indexOutOfRange(i, n int) string {
+	return fmt.Sprintf("orderstat: index %d out of range [0:%d]", i, n)
+}
+
+func sliceOutOfRange(i, j, n int) string {
+	return fmt.Sprintf("orderstat: slice bounds [%d:%d] out of range with length %d", i, j, n)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// snode
+////////////////////////////////////////////////////////////////////////////////
+
+// snode is a node of a Sequence. Its methods mirror the balancing code of
+// pnode, without the copying, with comparisons replaced by the position
+// relative to the size of the left subtree.
+type snode struct {
+	item        Item
+	left, right *snode
+	count       uint32
+	red         bool
+}
+
+// buildSequence links items into a subtree of the given black height, which
+// must be able to hold exactly len(items) items, and returns its root. It
+// builds the same shape as Tree.buildSubtree.
+func buildSequence(items []Item, height int) *snode {
+	if len(items) == 0 {
+		return nil
+	}
+	var n *snode
+	if rest := len(items) - 1; rest <= 2*maxSize(height-1) {
+		l := rest / 2
+		n = &snode{item: items[l]}
+		n.left = buildSequence(items[:l], height-1)
+		n.right = buildSequence(items[l+1:], height-1)
+	} else {
+		rest--
+		a, b := rest/3, rest/3
+		if rest%3 == 2 {
+			b++
+		}
+		red := &snode{item: items[a], red: true}
+		red.left = buildSequence(items[:a], height-1)
+		red.right = buildSequence(items[a+1:a+1+b], height-1)
+		red.count = red.left.size() + red.right.size() + 1
+		n = &snode{item: items[a+1+b], left: red}
+		n.right = buildSequence(items[a+2+b:], height-1)
+	}
+	n.count = n.left.size() + n.right.size() + 1
+	return n
+}
+
+func (n *snode) size() uint32 {
+	if n == nil {
+		return 0
+	}
+	return n.count
+}
+
+func (n *snode) isRed() bool {
+	return n != nil && n.red
+}
+
+// at returns the node at position i of the subtree rooted at n, which must
+// exist.
+func (n *snode) at(i uint32) *snode {
+	for {
+		switch lc := n.left.size(); {
+		case i < lc:
+			n = n.left
+		case i > lc:
+			i -= lc + 1
+			n = n.right
+		default:
+			return n
+		}
+	}
+}
+
+// appendRange appends the items at positions [i, j) of the subtree rooted at
+// n to items, visiting only the subtrees which overlap the range.
+func (n *snode) appendRange(items []Item, i, j uint32) []Item {
+	if n == nil || i >= j {
+		return items
+	}
+	lc := n.left.size()
+	if i < lc {
+		items = n.left.appendRange(items, i, min(j, lc))
+	}
+	if i <= lc && lc < j {
+		items = append(items, n.item)
+	}
+	if j > lc+1 {
+		items = n.right.appendRange(items, max(i, lc+1)-lc-1, j-lc-1)
+	}
+	return items
+}
+
+func (n *snode) ascend(f ItemIterator) bool {
+	if n == nil {
+		return true
+	}
+	return n.left.ascend(f) && f(n.item) && n.right.ascend(f)
+}
+
+func (n *snode) insertAt(i uint32, item Item) *snode {
+	if n == nil {
+		return &snode{item: item, count: 1, red: true}
+	}
+	if lc := n.left.size(); i <= lc {
+		n.left = n.left.insertAt(i, item)
+	} else {
+		n.right = n.right.insertAt(i-lc-1, item)
+	}
+	return n.fixUp()
+}
+
+// delAt removes the item at position i of the subtree rooted at n, which must
+// exist, and returns the new root of the subtree along with the removed item.
+func (n *snode) delAt(i uint32) (_ *snode, removed Item) {
+	if i < n.left.size() {
+		if !n.left.isRed() && !n.left.left.isRed() {
+			n = n.moveRedLeft()
+		}
+		n.left, removed = n.left.delAt(i)
+		return n.fixUp(), removed
+	}
+	if n.left.isRed() {
+		n = n.rotateRight()
+	}
+	if i == n.left.size() && n.right == nil {
+		return nil, n.item
+	}
+	if n.right != nil && !n.right.isRed() && !n.right.left.isRed() {
+		n = n.moveRedRight()
+	}
+	if lc := n.left.size(); i == lc {
+		var min *snode
+		removed = n.item
+		n.right, min = n.right.delMin()
+		n.item = min.item
+	} else {
+		n.right, removed = n.right.delAt(i - lc - 1)
+	}
+	return n.fixUp(), removed
+}
+
+// delMin removes the first node from the subtree rooted at n and returns it
+// so that it may be reused.
+func (n *snode) delMin() (_, min *snode) {
+	if n.left == nil {
+		return nil, n
+	}
+	if !n.left.isRed() && !n.left.left.isRed() {
+		n = n.moveRedLeft()
+	}
+	n.left, min = n.left.delMin()
+	return n.fixUp(), min
+}
+
+func (n *snode) fixUp() *snode {
+	if n.right.isRed() {
+		n = n.rotateLeft()
+	}
+	if n.left.isRed() && n.left.left.isRed() {
+		n = n.rotateRight()
+	}
+	if n.left.isRed() && n.right.isRed() {
+		n.colorFlip()
+	}
+	n.count = n.left.size() + n.right.size() + 1
+	return n
+}
+
+func (n *snode) rotateLeft() *snode {
+	x := n.right
+	n.right = x.left
+	x.left = n
+	x.red = n.red
+	n.red = true
+	n.count = n.left.size() + n.right.size() + 1
+	x.count = x.left.size() + x.right.size() + 1
+	return x
+}
+
+func (n *snode) rotateRight() *snode {
+	x := n.left
+	n.left = x.right
+	x.right = n
+	x.red = n.red
+	n.red = true
+	n.count = n.left.size() + n.right.size() + 1
+	x.count = x.left.size() + x.right.size() + 1
+	return x
+}
+
+func (n *snode) colorFlip() {
+	n.red = !n.red
+	if n.left != nil {
+		n.left.red = !n.left.red
+	}
+	if n.right != nil {
+		n.right.red = !n.right.red
+	}
+}
+
+func (n *snode) moveRedLeft() *snode {
+	n.colorFlip()
+	if n.right.left.isRed() {
+		n.right = n.right.rotateRight()
+		n = n.rotateLeft()
+		n.colorFlip()
+	}
+	return n
+}
+
+func (n *snode) moveRedRight() *snode {
+	n.colorFlip()
+	if n.left.left.isRed() {
+		n = n.rotateRight()
+		n.colorFlip()
+	}
+	return n
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Join and split
+////////////////////////////////////////////////////////////////////////////////
+
+// blackHeight returns the number of black nodes on every path from n to a
+// leaf.
+func (n *snode) blackHeight() (h int) {
+	for ; n != nil; n = n.left {
+		if !n.red {
+			h++
+		}
+	}
+	return h
+}
+
+// concat returns the root of a tree holding the items of l followed by those
+// of r, both of which must have black roots. The first node of r is removed
+// to join the two trees.
+func concat(l, r *snode) *snode {
+	switch {
+	case r == nil:
+		return l
+	case l == nil:
+		return r
+	}
+	if !r.left.isRed() && !r.right.isRed() {
+		r.red = true
+	}
+	r, x := r.delMin()
+	if r != nil {
+		r.red = false
+	}
+	root, _ := join(l, l.blackHeight(), x, r, r.blackHeight())
+	return root
+}
+
+// join returns the root of a tree holding the items of l, then x and then the
+// items of r, along with its black height. l and r must have black roots,
+// possibly nil, and black heights lh and rh, and x must not be linked into
+// any tree. It takes O(|lh-rh|+1) time.
+//
+// The shorter tree is linked with x as a red node at the level of the taller
+// tree's spine with the same black height, as if x had been inserted there,
+// and the spine is then fixed up as it is after an insertion.
+func join(l *snode, lh int, x, r *snode, rh int) (*snode, int) {
+	var root *snode
+	switch {
+	case lh > rh:
+		root = l.joinRight(x, r, lh, rh)
+	case lh < rh:
+		root = r.joinLeft(l, x, rh, lh)
+	default:
+		root = x.link(l, r)
+	}
+	h := max(lh, rh)
+	if root.red {
+		root.red = false
+		h++
+	}
+	return root, h
+}
+
+// joinRight descends the right spine of the subtree rooted at n, whose black
+// height is h, to link x and r below it.
+func (n *snode) joinRight(x, r *snode, h, rh int) *snode {
+	if h == rh && !n.isRed() {
+		return x.link(n, r)
+	}
+	if !n.red {
+		h--
+	}
+	n.right = n.right.joinRight(x, r, h, rh)
+	return n.fixUp()
+}
+
+// joinLeft descends the left spine of the subtree rooted at n, whose black
+// height is h, to link l and x below it.
+func (n *snode) joinLeft(l, x *snode, h, lh int) *snode {
+	if h == lh && !n.isRed() {
+		return x.link(l, n)
+	}
+	if !n.red {
+		h--
+	}
+	n.left = n.left.joinLeft(l, x, h, lh)
+	return n.fixUp()
+}
+
+// link makes x a red node with children l and r.
+func (x *snode) link(l, r *snode) *snode {
+	x.left, x.right = l, r
+	x.red = true
+	x.count = l.size() + r.size() + 1
+	return x
+}
+
+// split splits the subtree rooted at n, whose black height is h, into trees
+// holding its first i items and the remaining items, returning their black
+// roots and their black heights. It takes O(log n) time, as the costs of the
+// joins along the path telescope.
+func (n *snode) split(i uint32, h int) (l *snode, lh int, r *snode, rh int) {
+	if n == nil {
+		return nil, 0, nil, 0
+	}
+	if !n.red {
+		h--
+	}
+	left, lch := n.left.detach(h)
+	right, rch := n.right.detach(h)
+	var mid *snode
+	var mh int
+	if lc := left.size(); i <= lc {
+		l, lh, mid, mh = left.split(i, lch)
+		r, rh = join(mid, mh, n, right, rch)
+	} else {
+		mid, mh, r, rh = right.split(i-lc-1, rch)
+		l, lh = join(left, lch, n, mid, mh)
+	}
+	return l, lh, r, rh
+}
+
+// detach turns the child n of a node whose children have black height h into
+// a tree with a black root, and returns it along with its black height.
+func (n *snode) detach(h int) (*snode, int) {
+	if n != nil && n.red {
+		n.red = false
+		h++
+	}
+	return n, h
+}
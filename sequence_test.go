@@ -0,0 +1,279 @@
+package orderstat
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// validate checks the balance, coloring and counts of the sequence's tree.
+func (s *Sequence) validate() error {
+	if s.root.isRed() {
+		return fmt.Errorf("red root")
+	}
+	_, err := s.root.validate()
+	return err
+}
+
+func (n *snode) validate() (blackHeight int, err error) {
+	if n == nil {
+		return 0, nil
+	}
+	if n.right.isRed() {
+		return 0, fmt.Errorf("right leaning red link at %v", n.item)
+	}
+	if n.red && n.left.isRed() {
+		return 0, fmt.Errorf("consecutive red links at %v", n.item)
+	}
+	lh, err := n.left.validate()
+	if err != nil {
+		return 0, err
+	}
+	rh, err := n.right.validate()
+	if err != nil {
+		return 0, err
+	}
+	if lh != rh {
+		return 0, fmt.Errorf("black height mismatch at %v: %d != %d", n.item, lh, rh)
+	}
+	if n.count != n.left.size()+n.right.size()+1 {
+		return 0, fmt.Errorf("count mismatch at %v", n.item)
+	}
+	if !n.red {
+		lh++
+	}
+	return lh, nil
+}
+
+func TestSequence(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	s := NewSequence()
+	var model []Item
+	for i := 0; i < 10000; i++ {
+		switch op := rng.Intn(10); {
+		case op < 5 || len(model) == 0:
+			pos := rng.Intn(len(model) + 1)
+			s.InsertAt(pos, intItem(i))
+			model = append(model[:pos], append([]Item{intItem(i)}, model[pos:]...)...)
+		case op < 8:
+			pos := rng.Intn(len(model))
+			assert.Equal(t, model[pos], s.RemoveAt(pos))
+			model = append(model[:pos], model[pos+1:]...)
+		default:
+			pos := rng.Intn(len(model))
+			assert.Equal(t, model[pos], s.Set(pos, intItem(-i)))
+			model[pos] = intItem(-i)
+		}
+		require.Nil(t, s.validate())
+		require.Equal(t, len(model), s.Len())
+	}
+	assert.Equal(t, model, s.Slice(0, s.Len()))
+	for i, item := range model {
+		assert.Equal(t, item, s.At(i))
+	}
+	for i := 0; i < 100; i++ {
+		lo := rng.Intn(len(model) + 1)
+		hi := lo + rng.Intn(len(model)-lo+1)
+		assert.Equal(t, model[lo:hi], s.Slice(lo, hi))
+	}
+	i := 0
+	for pos, item := range s.All() {
+		assert.Equal(t, i, pos)
+		assert.Equal(t, model[i], item)
+		i++
+	}
+	assert.Equal(t, len(model), i)
+}
+
+func TestSequenceConcatSplit(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	for _, sizes := range [][2]int{{0, 0}, {0, 5}, {5, 0}, {1, 1}, {3, 100}, {100, 3}, {1000, 1000}, {2000, 7}} {
+		a, b := make([]Item, sizes[0]), make([]Item, sizes[1])
+		for i := range a {
+			a[i] = intItem(i)
+		}
+		for i := range b {
+			b[i] = intItem(len(a) + i)
+		}
+		s, other := NewSequence(a...), NewSequence(b...)
+		s.Concat(other)
+		require.Nil(t, s.validate())
+		assert.Equal(t, append(append([]Item{}, a...), b...), s.Slice(0, s.Len()))
+		assert.Equal(t, 0, other.Len())
+
+		all := s.Slice(0, s.Len())
+		for _, at := range []int{0, len(all), rng.Intn(len(all) + 1), len(all) - min(len(all), 2)} {
+			s := NewSequence(all...)
+			tail := s.SplitAt(at)
+			require.Nil(t, s.validate())
+			require.Nil(t, tail.validate())
+			assert.Equal(t, all[:at], s.Slice(0, s.Len()))
+			assert.Equal(t, all[at:], tail.Slice(0, tail.Len()))
+			// Both halves remain usable.
+			s.Append(intItem(-1))
+			tail.InsertAt(0, intItem(-2))
+			assert.Equal(t, intItem(-1), s.At(at))
+			assert.Equal(t, intItem(-2), tail.At(0))
+		}
+	}
+}
+
+func TestSequenceSplitConcatModel(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	var model [][]Item
+	var seqs []*Sequence
+	add := func(items []Item) {
+		model = append(model, items)
+		seqs = append(seqs, NewSequence(items...))
+	}
+	for i := 0; i < 4; i++ {
+		items := make([]Item, rng.Intn(200))
+		for j := range items {
+			items[j] = intItem(1000*i + j)
+		}
+		add(items)
+	}
+	for i := 0; i < 2000; i++ {
+		a := rng.Intn(len(seqs))
+		switch op := rng.Intn(4); {
+		case op == 0 && len(seqs) > 1:
+			b := rng.Intn(len(seqs))
+			if a == b {
+				continue
+			}
+			seqs[a].Concat(seqs[b])
+			model[a] = append(model[a], model[b]...)
+			model[b] = nil
+		case op == 1:
+			at := rng.Intn(len(model[a]) + 1)
+			seqs = append(seqs, seqs[a].SplitAt(at))
+			model = append(model, append([]Item{}, model[a][at:]...))
+			model[a] = model[a][:at:at]
+		case op == 2:
+			at := rng.Intn(len(model[a]) + 1)
+			seqs[a].InsertAt(at, intItem(-i))
+			model[a] = append(model[a][:at], append([]Item{intItem(-i)}, model[a][at:]...)...)
+		case len(model[a]) > 0:
+			at := rng.Intn(len(model[a]))
+			assert.Equal(t, model[a][at], seqs[a].RemoveAt(at))
+			model[a] = append(model[a][:at], model[a][at+1:]...)
+		}
+		for j, s := range seqs {
+			require.Nil(t, s.validate(), "step %d", i)
+			require.Equal(t, len(model[j]), s.Len())
+		}
+	}
+	for j, s := range seqs {
+		assert.Equal(t, len(model[j]), len(s.Slice(0, s.Len())))
+		for i, item := range s.All() {
+			assert.Equal(t, model[j][i], item)
+		}
+	}
+}
+
+func TestSequenceRemoveRange(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	items := make([]Item, 500)
+	for i := range items {
+		items[i] = intItem(i)
+	}
+	s := NewSequence(items...)
+	model := append([]Item{}, items...)
+	for len(model) > 0 {
+		i := rng.Intn(len(model))
+		j := i + rng.Intn(min(len(model)-i, 20)+1)
+		s.RemoveRange(i, j)
+		model = append(model[:i], model[j:]...)
+		require.Nil(t, s.validate())
+		require.Equal(t, model, s.Slice(0, s.Len()))
+	}
+	assert.Panics(t, func() { s.RemoveRange(0, 1) })
+}
+
+func TestSequenceSplitDiscardConcat(t *testing.T) {
+	items := make([]Item, 1000)
+	for i := range items {
+		items[i] = intItem(i)
+	}
+	s := NewSequence(items...)
+	rng := rand.New(rand.NewSource(0))
+	// edit inserts ten items and then cuts ten items out by splitting them
+	// off and discarding them, as an editor buffer does.
+	edit := func(n int) {
+		for k := 0; k < n; k++ {
+			at := rng.Intn(s.Len() + 1)
+			for j := 0; j < 10; j++ {
+				s.InsertAt(at, intItem(-j))
+			}
+			at = rng.Intn(s.Len() - 9)
+			tail := s.SplitAt(at)
+			s.Concat(tail.SplitAt(10))
+		}
+	}
+	heap := func() uint64 {
+		runtime.GC()
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		return ms.HeapAlloc
+	}
+	edit(5000)
+	before := heap()
+	edit(50000)
+	after := heap()
+	require.Nil(t, s.validate())
+	assert.Equal(t, 1000, s.Len())
+	// Discarded items are reclaimed. Retaining them would take
+	// 500000 nodes, well over 10MB.
+	assert.True(t, after < before+1<<20, "heap grew from %d to %d", before, after)
+}
+
+func TestSequenceConcurrent(t *testing.T) {
+	items := make([]Item, 1000)
+	for i := range items {
+		items[i] = intItem(i)
+	}
+	s := NewSequence(items...)
+	halves := []*Sequence{s, s.SplitAt(500)}
+	// The halves of a split share no nodes, so each may be used by its own
+	// goroutine.
+	var wg sync.WaitGroup
+	for _, h := range halves {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				h.InsertAt(i%h.Len(), intItem(-i))
+				h.RemoveAt((i * 7) % h.Len())
+				tail := h.SplitAt(h.Len() / 2)
+				h.Concat(tail)
+			}
+		}()
+	}
+	wg.Wait()
+	for _, h := range halves {
+		require.Nil(t, h.validate())
+		assert.Equal(t, 500, h.Len())
+	}
+}
+
+func TestSequenceBounds(t *testing.T) {
+	s := NewSequence(intItem(0), nil, intItem(2))
+	assert.Nil(t, s.At(1))
+	assert.Panics(t, func() { s.At(3) })
+	assert.Panics(t, func() { s.At(-1) })
+	assert.Panics(t, func() { s.Set(3, nil) })
+	assert.Panics(t, func() { s.InsertAt(4, nil) })
+	assert.Panics(t, func() { s.RemoveAt(3) })
+	assert.Panics(t, func() { s.Slice(2, 1) })
+	assert.Panics(t, func() { s.Slice(0, 4) })
+	assert.Panics(t, func() { s.SplitAt(4) })
+	assert.Panics(t, func() { s.Concat(s) })
+	assert.Equal(t, []Item{}, s.Slice(3, 3))
+	s.InsertAt(3, intItem(3))
+	assert.Equal(t, intItem(3), s.At(3))
+}
@@ -0,0 +1,135 @@
+package orderstat
+
+import "math/bits"
+
+////////////////////////////////////////////////////////////////////////////////
+// Set algebra
+////////////////////////////////////////////////////////////////////////////////
+
+// Resolver chooses which of two equal items, x from the first tree and y from
+// the second, is kept in the result of a set operation. A nil Resolver keeps
+// x.
+type Resolver func(x, y Item) Item
+
+// Union returns a new tree holding every item which is in a or b. Items which
+// are in both are resolved with resolve.
+//
+// The set operations merge the trees in order and build the result in a
+// single pass, so they take O(n + m) time. Both trees must use the same
+// order, and the result uses the order of a. Neither a nor b is modified.
+func Union(a, b *Tree, resolve Resolver) *Tree {
+	return merge(a, b, resolve, true, true, true)
+}
+
+// Intersection returns a new tree holding every item which is in both a and
+// b, resolved with resolve.
+func Intersection(a, b *Tree, resolve Resolver) *Tree {
+	return merge(a, b, resolve, false, true, false)
+}
+
+// Difference returns a new tree holding every item in a which is not in b.
+func Difference(a, b *Tree) *Tree {
+	return merge(a, b, nil, true, false, false)
+}
+
+// SymmetricDifference returns a new tree holding every item which is in
+// exactly one of a and b.
+func SymmetricDifference(a, b *Tree) *Tree {
+	return merge(a, b, nil, true, false, true)
+}
+
+// merge walks a and b in order and builds a tree from the items which are
+// only in a if onlyA is set, in both if both is set and only in b if onlyB is
+// set.
+func merge(a, b *Tree, resolve Resolver, onlyA, both, onlyB bool) *Tree {
+	var size int
+	if onlyA || both {
+		size += a.Len()
+	}
+	if onlyB {
+		size += b.Len()
+	}
+	items := make([]Item, 0, size)
+	ia, okA := a.root.min(a)
+	ib, okB := b.root.min(b)
+	for okA || okB {
+		var c int
+		switch {
+		case !okB:
+			c = -1
+		case !okA:
+			c = 1
+		default:
+			c = a.cmp(ia.item, ib.item)
+		}
+		switch {
+		case c < 0:
+			if onlyA {
+				items = append(items, ia.item)
+			}
+			ia, okA = ia.next(a)
+		case c > 0:
+			if onlyB {
+				items = append(items, ib.item)
+			}
+			ib, okB = ib.next(b)
+		default:
+			if both {
+				item := ia.item
+				if resolve != nil {
+					item = resolve(ia.item, ib.item)
+				}
+				items = append(items, item)
+			}
+			ia, okA = ia.next(a)
+			ib, okB = ib.next(b)
+		}
+	}
+	t := NewTreeWithLess(a.less)
+	t.cmp = a.cmp
+	t.build(items)
+	return t
+}
+
+// IsSubset returns true if every item in a is also in b.
+func IsSubset(a, b *Tree) bool {
+	n, m := a.Len(), b.Len()
+	if n > m {
+		return false
+	}
+	if n*bits.Len(uint(m)) < n+m {
+		// Looking up the few items of a is cheaper than a merge.
+		for it, ok := a.root.min(a); ok; it, ok = it.next(a) {
+			if !b.Has(it.item) {
+				return false
+			}
+		}
+		return true
+	}
+	ib, okB := b.root.min(b)
+	for ia, okA := a.root.min(a); okA; ia, okA = ia.next(a) {
+		for okB && a.cmp(ib.item, ia.item) < 0 {
+			ib, okB = ib.next(b)
+		}
+		if !okB || a.cmp(ib.item, ia.item) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Equal returns true if a and b hold the same items, where items are the same
+// if they are equal in the order of a.
+func Equal(a, b *Tree) bool {
+	if a.Len() != b.Len() {
+		return false
+	}
+	ib, _ := b.root.min(b)
+	for ia, ok := a.root.min(a); ok; ia, ok = ia.next(a) {
+		if a.cmp(ia.item, ib.item) != 0 {
+			return false
+		}
+		ib, _ = ib.next(b)
+	}
+	return true
+}
@@ -0,0 +1,129 @@
+package orderstat
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func treeOf(items ...Item) *Tree {
+	t := NewTree()
+	for _, item := range items {
+		t.ReplaceOrInsert(item)
+	}
+	return t
+}
+
+func TestSetOperations(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	for run := 0; run < 200; run++ {
+		inA, inB := map[int]bool{}, map[int]bool{}
+		a, b := NewTree(), NewTree()
+		for i := rng.Intn(50); i > 0; i-- {
+			v := rng.Intn(60)
+			inA[v] = true
+			a.ReplaceOrInsert(intItem(v))
+		}
+		for i := rng.Intn(50); i > 0; i-- {
+			v := rng.Intn(60)
+			inB[v] = true
+			b.ReplaceOrInsert(intItem(v))
+		}
+		want := func(keep func(x, y bool) bool) []Item {
+			items := []Item{}
+			for v := 0; v < 60; v++ {
+				if keep(inA[v], inB[v]) {
+					items = append(items, intItem(v))
+				}
+			}
+			return items
+		}
+		check := func(want []Item, got *Tree) {
+			require.Nil(t, got.Validate())
+			assert.Equal(t, len(want), got.Len())
+			assert.Equal(t, want, got.BottomK(got.Len()+1))
+		}
+		check(want(func(x, y bool) bool { return x || y }), Union(a, b, nil))
+		check(want(func(x, y bool) bool { return x && y }), Intersection(a, b, nil))
+		check(want(func(x, y bool) bool { return x && !y }), Difference(a, b))
+		check(want(func(x, y bool) bool { return x != y }), SymmetricDifference(a, b))
+
+		subset := true
+		for v := range inA {
+			subset = subset && inB[v]
+		}
+		assert.Equal(t, subset, IsSubset(a, b))
+		assert.True(t, IsSubset(Intersection(a, b, nil), a))
+		assert.True(t, IsSubset(a, Union(a, b, nil)))
+		assert.Equal(t, len(want(func(x, y bool) bool { return x != y })) == 0, Equal(a, b))
+		assert.True(t, Equal(a, Union(a, a, nil)))
+	}
+}
+
+func TestSetOperationsResolve(t *testing.T) {
+	a := treeOf(kv("a", "1"), kv("b", "1"), kv("c", "1"))
+	b := treeOf(kv("b", "2"), kv("c", "2"), kv("d", "2"))
+	concat := func(x, y Item) Item {
+		return kv(x.(keyValue).k, x.(keyValue).v+y.(keyValue).v)
+	}
+	assert.Equal(t, []Item{kv("a", "1"), kv("b", "12"), kv("c", "12"), kv("d", "2")},
+		Union(a, b, concat).BottomK(10))
+	assert.Equal(t, []Item{kv("a", "1"), kv("b", "1"), kv("c", "1"), kv("d", "2")},
+		Union(a, b, nil).BottomK(10))
+	assert.Equal(t, []Item{kv("b", "2"), kv("c", "2")},
+		Intersection(a, b, func(x, y Item) Item { return y }).BottomK(10))
+	assert.Equal(t, []Item{kv("a", "1")}, Difference(a, b).BottomK(10))
+	assert.Equal(t, []Item{kv("a", "1"), kv("d", "2")}, SymmetricDifference(a, b).BottomK(10))
+	// Items are compared by key alone.
+	assert.True(t, Equal(treeOf(kv("a", "1")), treeOf(kv("a", "2"))))
+	assert.False(t, Equal(treeOf(kv("a", "1")), treeOf(kv("b", "1"))))
+	assert.True(t, IsSubset(NewTree(), a))
+	assert.False(t, IsSubset(a, NewTree()))
+	assert.True(t, Equal(NewTree(), NewTree()))
+}
+
+func TestSetOperationsOrder(t *testing.T) {
+	// The result uses the order of the first tree.
+	reverse := func(x, y Item) bool { return y.Less(x) }
+	a, b := NewTreeWithLess(reverse), NewTreeWithLess(reverse)
+	for i := 0; i < 10; i++ {
+		a.ReplaceOrInsert(intItem(i))
+		b.ReplaceOrInsert(intItem(i + 5))
+	}
+	u := Union(a, b, nil)
+	require.Nil(t, u.Validate())
+	got := u.BottomK(20)
+	assert.Len(t, got, 15)
+	assert.True(t, sort.SliceIsSorted(got, func(i, j int) bool { return got[j].Less(got[i]) }))
+	u.ReplaceOrInsert(intItem(100))
+	assert.Equal(t, intItem(100), u.Min())
+}
+
+func BenchmarkUnion(b *testing.B) {
+	x, y := NewTree(), NewTree()
+	for i := 0; i < 1<<16; i++ {
+		x.ReplaceOrInsert(intItem(2 * i))
+		y.ReplaceOrInsert(intItem(3 * i))
+	}
+	b.Run("Merge", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			Union(x, y, nil)
+		}
+	})
+	b.Run("ReplaceOrInsert", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			u := NewTree()
+			for it := range x.All() {
+				u.ReplaceOrInsert(it)
+			}
+			for it := range y.All() {
+				if !u.Has(it) {
+					u.ReplaceOrInsert(it)
+				}
+			}
+		}
+	})
+}
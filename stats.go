@@ -0,0 +1,74 @@
+package orderstat
+
+import "unsafe"
+
+// Stats describes the shape and memory usage of a Tree.
+type Stats struct {
+	// Nodes is the number of items in the tree.
+	Nodes int
+	// RedNodes is the number of nodes which are colored red.
+	RedNodes int
+	// Height is the number of nodes on the longest path from the root to a
+	// leaf.
+	Height int
+	// BlackHeight is the number of black nodes on every path from the root to
+	// a leaf.
+	BlackHeight int
+	// Capacity is the number of nodes in the tree's node arena.
+	Capacity int
+	// Free is the number of unused nodes in the arena which are available for
+	// reuse.
+	Free int
+	// EstimatedBytes is the size in bytes of the Capacity nodes in the arena,
+	// including the per-node bookkeeping kept for Diff once the tree has been
+	// cloned. It does not include memory referenced by the items themselves.
+	EstimatedBytes int
+	// AllocatedBytes is like EstimatedBytes but counts the full capacity of
+	// the slices backing the arena, which may exceed Capacity nodes.
+	AllocatedBytes int
+	// DepthHistogram holds the number of nodes at each depth, where the root
+	// is at depth 0.
+	DepthHistogram []int
+}
+
+// Stats returns statistics about the shape and memory usage of the tree. It
+// takes time linear in the capacity of the tree.
+func (t *Tree) Stats() Stats {
+	const nodeSize, stampSize = int(unsafe.Sizeof(node{})), int(unsafe.Sizeof(uint64(0)))
+	var stamps []uint64
+	if tr := t.tracking.Load(); tr != nil {
+		stamps = tr.stamps
+	}
+	s := Stats{
+		Nodes:          t.Len(),
+		Capacity:       len(t.list),
+		EstimatedBytes: len(t.list)*nodeSize + len(stamps)*stampSize,
+		AllocatedBytes: cap(t.list)*nodeSize + cap(stamps)*stampSize,
+	}
+	for it := t.root; it.node != nil; it = it.l(t) {
+		if !it.isRed() {
+			s.BlackHeight++
+		}
+	}
+	for it := t.fp; it.node != nil; it = it.r(t) {
+		s.Free++
+	}
+	t.root.stats(t, 0, &s)
+	return s
+}
+
+func (it iterator) stats(t *Tree, depth int, s *Stats) {
+	if it.node == nil {
+		return
+	}
+	if depth == len(s.DepthHistogram) {
+		s.DepthHistogram = append(s.DepthHistogram, 0)
+		s.Height = depth + 1
+	}
+	s.DepthHistogram[depth]++
+	if it.isRed() {
+		s.RedNodes++
+	}
+	it.l(t).stats(t, depth+1, s)
+	it.r(t).stats(t, depth+1, s)
+}
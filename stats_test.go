@@ -0,0 +1,59 @@
+package orderstat
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	tr := NewTree()
+	assert.Equal(t, Stats{}, tr.Stats())
+
+	const N = 1000
+	for _, i := range rand.Perm(N) {
+		tr.ReplaceOrInsert(intItem(i))
+	}
+	for i := 0; i < N/2; i++ {
+		tr.Delete(intItem(2 * i))
+	}
+	s := tr.Stats()
+	assert.Equal(t, N/2, s.Nodes)
+	assert.Equal(t, 1024, s.Capacity)
+	assert.Equal(t, s.Capacity-s.Nodes, s.Free)
+	nodeSize := int(unsafe.Sizeof(node{}))
+	assert.Equal(t, 1024*nodeSize, s.EstimatedBytes)
+	assert.Equal(t, cap(tr.list)*nodeSize, s.AllocatedBytes)
+	assert.True(t, s.AllocatedBytes >= s.EstimatedBytes)
+	assert.Equal(t, s.Height, len(s.DepthHistogram))
+	assert.Equal(t, 1, s.DepthHistogram[0])
+	total := 0
+	for _, n := range s.DepthHistogram {
+		total += n
+	}
+	assert.Equal(t, s.Nodes, total)
+	// A red-black tree's height is at most twice its black height and at most
+	// 2 log2(n + 1).
+	assert.True(t, s.Height <= 2*s.BlackHeight, "%+v", s)
+	assert.True(t, float64(s.Height) <= 2*math.Log2(float64(s.Nodes+1)), "%+v", s)
+	assert.True(t, s.RedNodes > 0 && s.RedNodes < s.Nodes, "%+v", s)
+}
+
+func TestStatsClone(t *testing.T) {
+	tr := NewTree()
+	for i := 0; i < 100; i++ {
+		tr.ReplaceOrInsert(intItem(i))
+	}
+	before := tr.Stats()
+	c := tr.Clone()
+	// Once cloned, both trees also own a stamp for every node in the arena.
+	stamps := before.Capacity * int(unsafe.Sizeof(uint64(0)))
+	for _, s := range []Stats{tr.Stats(), c.Stats()} {
+		assert.Equal(t, before.Capacity, s.Capacity)
+		assert.Equal(t, before.EstimatedBytes+stamps, s.EstimatedBytes)
+		assert.True(t, s.AllocatedBytes >= s.EstimatedBytes, "%+v", s)
+	}
+}
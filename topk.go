@@ -0,0 +1,93 @@
+package orderstat
+
+////////////////////////////////////////////////////////////////////////////////
+// Top-K
+////////////////////////////////////////////////////////////////////////////////
+
+// TopK returns the k largest items in the tree in descending order. If the
+// tree holds fewer than k items, all of them are returned.
+func (t *Tree) TopK(k int) []Item {
+	return t.collect(IterOptions{Direction: Descending}, k)
+}
+
+// BottomK returns the k smallest items in the tree in ascending order. If the
+// tree holds fewer than k items, all of them are returned.
+func (t *Tree) BottomK(k int) []Item {
+	return t.collect(IterOptions{}, k)
+}
+
+// TopKInRange returns the k largest items in the tree within the range
+// [greaterOrEqual, lessThan) in descending order.
+func (t *Tree) TopKInRange(greaterOrEqual, lessThan Item, k int) []Item {
+	return t.collect(IterOptions{
+		Lower:     InclusiveBound(greaterOrEqual),
+		Upper:     ExclusiveBound(lessThan),
+		Direction: Descending,
+	}, k)
+}
+
+// collect returns up to k of the items visited by Iterate with opts.
+func (t *Tree) collect(opts IterOptions, k int) []Item {
+	if k <= 0 {
+		return nil
+	}
+	opts.Limit = k
+	items := make([]Item, 0, min(k, t.Len()))
+	t.Iterate(opts, func(item Item) bool {
+		items = append(items, item)
+		return true
+	})
+	return items
+}
+
+// TopKTracker keeps the k largest of a stream of items.
+//
+// Items are held in a Tree of at most k items. Once the tracker is full, an
+// item which is larger than the smallest tracked item evicts it with
+// DeleteMin, and any other item is rejected without modifying the tree.
+type TopKTracker struct {
+	k int
+	t *Tree
+}
+
+// NewTopKTracker creates a TopKTracker which keeps the k largest items.
+func NewTopKTracker(k int) *TopKTracker {
+	return &TopKTracker{k: k, t: NewTree()}
+}
+
+// Add offers item to the tracker. It returns the item which is no longer
+// tracked as a result, or nil if nothing was dropped. The dropped item is the
+// tracked item equal to item if item replaced it, the evicted smallest item,
+// or item itself if it was rejected.
+func (tr *TopKTracker) Add(item Item) (dropped Item) {
+	if tr.k <= 0 {
+		return item
+	}
+	if tr.t.Len() >= tr.k && tr.t.less(item, tr.t.Min()) {
+		return item
+	}
+	if replaced := tr.t.ReplaceOrInsert(item); replaced != nil {
+		return replaced
+	}
+	if tr.t.Len() > tr.k {
+		return tr.t.DeleteMin()
+	}
+	return nil
+}
+
+// Len returns the number of items currently tracked.
+func (tr *TopKTracker) Len() int {
+	return tr.t.Len()
+}
+
+// Threshold returns the smallest tracked item, which an item must be at least
+// as large as to be tracked once the tracker is full. It returns nil if no
+// items are tracked.
+func (tr *TopKTracker) Threshold() Item {
+	return tr.t.Min()
+}
+
+// Items returns the tracked items in descending order.
+func (tr *TopKTracker) Items() []Item {
+	return tr.t.TopK(tr.k)
+}
@@ -0,0 +1,84 @@
+package orderstat
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func intItems(ints ...int) []Item {
+	items := make([]Item, len(ints))
+	for i, v := range ints {
+		items[i] = intItem(v)
+	}
+	return items
+}
+
+func TestTopK(t *testing.T) {
+	tr := NewTree()
+	for _, i := range rand.Perm(10) {
+		tr.ReplaceOrInsert(intItem(i))
+	}
+	assert.Equal(t, intItems(9, 8, 7), tr.TopK(3))
+	assert.Equal(t, intItems(0, 1, 2), tr.BottomK(3))
+	assert.Equal(t, intItems(9, 8, 7, 6, 5, 4, 3, 2, 1, 0), tr.TopK(100))
+	assert.Equal(t, intItems(0, 1, 2, 3, 4, 5, 6, 7, 8, 9), tr.BottomK(10))
+	assert.Nil(t, tr.TopK(0))
+	assert.Nil(t, tr.BottomK(-1))
+	assert.Equal(t, intItems(6, 5), tr.TopKInRange(intItem(3), intItem(7), 2))
+	assert.Equal(t, intItems(6, 5, 4, 3), tr.TopKInRange(intItem(3), intItem(7), 10))
+	assert.Empty(t, tr.TopKInRange(intItem(3), intItem(3), 10))
+	assert.Empty(t, NewTree().TopK(3))
+}
+
+func TestTopKTracker(t *testing.T) {
+	const K = 10
+	tr := NewTopKTracker(K)
+	assert.Nil(t, tr.Threshold())
+	var all []int
+	for _, v := range rand.Perm(1000) {
+		v %= 500
+		dropped := tr.Add(intItem(v))
+		all = append(all, v)
+		assert.True(t, tr.Len() <= K)
+		switch {
+		case dropped == intItem(v) && tr.t.Has(dropped):
+			// The tracked item equal to v was replaced by it.
+		case dropped != nil:
+			assert.Equal(t, K, tr.Len())
+			assert.False(t, tr.Threshold().Less(dropped), "%v dropped below %v", dropped, tr.Threshold())
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(all)))
+	var want []int
+	for _, v := range all {
+		if len(want) == 0 || want[len(want)-1] != v {
+			want = append(want, v)
+		}
+	}
+	assert.Equal(t, intItems(want[:K]...), tr.Items())
+	assert.Equal(t, intItem(want[K-1]), tr.Threshold())
+
+	assert.Equal(t, intItem(1), NewTopKTracker(0).Add(intItem(1)))
+	small := NewTopKTracker(2)
+	assert.Nil(t, small.Add(intItem(5)))
+	assert.Nil(t, small.Add(intItem(3)))
+	assert.Equal(t, intItem(1), small.Add(intItem(1)))
+	assert.Equal(t, intItem(3), small.Add(intItem(3)))
+	assert.Equal(t, intItem(3), small.Add(intItem(7)))
+	assert.Equal(t, intItems(7, 5), small.Items())
+}
+
+func TestTopKTrackerReplace(t *testing.T) {
+	tr := NewTopKTracker(2)
+	assert.Nil(t, tr.Add(kv("b", "1")))
+	assert.Nil(t, tr.Add(kv("c", "1")))
+	// An equal item replaces the tracked one, which is no longer tracked.
+	assert.Equal(t, kv("b", "1"), tr.Add(kv("b", "2")))
+	assert.Equal(t, 2, tr.Len())
+	assert.Equal(t, []Item{kv("c", "1"), kv("b", "2")}, tr.Items())
+	assert.Equal(t, kv("b", "2"), tr.Add(kv("d", "1")))
+	assert.Equal(t, kv("c", "1"), tr.Threshold())
+}
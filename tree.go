@@ -3,6 +3,7 @@ package orderstat
 import (
 	"fmt"
 	"math"
+	"sync/atomic"
 )
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -34,19 +35,66 @@ type Tree struct {
 	root iterator
 	fp   iterator
 	list []node
+	less func(a, b Item) bool
+	cmp  func(a, b Item) int
+
+	// gen is incremented on every mutation so that iteration can detect
+	// modifications made by the iterator callback.
+	gen uint64
+
+	observer Observer
+
+	// tracking is set up by the first call to Clone so that Diff can skip
+	// subtrees which a tree shares with its clones. It is accessed
+	// atomically because Clone may be called concurrently with other reads.
+	// stamp is the stamp issued to the current mutation.
+	tracking atomic.Pointer[tracking]
+	stamp    uint64
 }
 
-// NewTree creates a new Tree.
+// tracking records which nodes of a tree each mutation modifies.
+type tracking struct {
+	// clock is shared by trees cloned from one another and issues a
+	// distinct stamp to each mutation of any of them.
+	clock *atomic.Uint64
+	// stamps holds the stamp of the mutation which last modified each node
+	// or any of its descendants.
+	stamps []uint64
+}
+
+// NewTree creates a new Tree which orders items using Item.Less.
 func NewTree() *Tree {
-	t := &Tree{}
+	t := NewTreeWithLess(itemLess)
+	t.cmp = itemCompare
+	return t
+}
+
+// NewTreeWithLess creates a new Tree which orders items using less rather
+// than Item.Less. This allows multiple trees to hold the same items in
+// different orders without wrapping them. The items must still implement
+// Item, but their Less method is never called by the tree.
+//
+// less must provide a strict weak ordering. If !less(a, b) && !less(b, a),
+// we treat this to mean a == b.
+func NewTreeWithLess(less func(a, b Item) bool) *Tree {
+	t := &Tree{less: less, cmp: lessCompare(less)}
 	t.root.np = null
 	t.fp.np = null
 	return t
 }
 
 func (t *Tree) Select(i int) Item {
+	if it, ok := t.at(i); ok {
+		return it.item
+	}
+	return nil
+}
+
+// at returns an iterator positioned at the item with rank i. It returns false
+// if i is out of range.
+func (t *Tree) at(i int) (iterator, bool) {
 	if i < 0 || i >= int(t.root.count()) {
-		return nil
+		return iterator{np: null}, false
 	}
 	rank := uint32(i)
 	below := uint32(0)
@@ -61,7 +109,7 @@ func (t *Tree) Select(i int) Item {
 			below = cur + 1
 			it = it.r(t)
 		} else {
-			return it.item
+			return it, true
 		}
 	}
 }
@@ -71,75 +119,103 @@ func (t *Tree) Rank(item Item) int {
 	if ok := it.seek(t, item, seekEQ); !ok {
 		return -1
 	}
-	rank := it.l(t).count()
-	p := it.p(t)
-	for p.node != nil {
-		if p.node.r == it.np {
-			rank += p.l(t).count() + 1
-		}
-		it, p = p, p.p(t)
-	}
-	return int(rank)
+	return it.rank(t)
 }
 
 // Ascend calls the iterator for every value in the tree within the range
 // [first, last], until iterator returns false.
 func (t *Tree) Ascend(f ItemIterator) {
-	for it, ok := t.root.min(t); ok && f(it.item); it, ok = it.next(t) {
+	t.Iterate(IterOptions{}, f)
+}
+
+// AscendMutable calls the iterator for every value in the tree within the
+// range [first, last], until iterator returns false.
+//
+// Unlike Ascend, the iterator may insert items into or delete items from t.
+// After any such mutation iteration resumes from the smallest item greater
+// than the last item passed to the iterator, so items inserted behind the
+// iteration point are not visited and items ahead of it are.
+func (t *Tree) AscendMutable(f ItemIterator) {
+	for it, ok := t.root.min(t); ok; {
+		item, gen := it.item, t.gen
+		if !f(item) {
+			return
+		}
+		if t.gen == gen {
+			it, ok = it.next(t)
+		} else {
+			ok = it.seek(t, item, seekGT)
+		}
 	}
 }
 
 // AscendGreaterOrEqual calls the iterator for every value in the tree within
 // the range [pivot, last], until iterator returns false.
 func (t *Tree) AscendGreaterOrEqual(pivot Item, f ItemIterator) {
-	var it iterator
-	ok := it.seek(t, pivot, seekGTE)
-	for ; ok && f(it.item); it, ok = it.next(t) {
-	}
+	t.Iterate(IterOptions{Lower: InclusiveBound(pivot)}, f)
 }
 
 // AscendLessThan calls the iterator for every value in the tree within the range
 // [first, pivot), until iterator returns false.
 func (t *Tree) AscendLessThan(pivot Item, f ItemIterator) {
-	var limit iterator
-	if ok := limit.seek(t, pivot, seekLT); !ok {
-		return
-	}
-	it, ok := t.root.min(t)
-	for ; ok && f(it.item) && it.np != limit.np; it, ok = it.next(t) {
-	}
+	t.Iterate(IterOptions{Upper: ExclusiveBound(pivot)}, f)
 }
 
-// AscendLessThan calls the iterator for every value in the tree within the range
-// [first, pivot), until iterator returns false.
-func (t *Tree) AscendRange(greatorOrEqual, lessThan Item, f ItemIterator) {
-	var limit iterator
-	if ok := limit.seek(t, lessThan, seekLT); !ok {
-		return
-	}
-	var it iterator
-	ok := it.seek(t, greatorOrEqual, seekGTE)
-	for ; ok && f(it.item) && it.np != limit.np; it, ok = it.next(t) {
-	}
+// AscendRange calls the iterator for every value in the tree within the range
+// [greaterOrEqual, lessThan), until iterator returns false.
+func (t *Tree) AscendRange(greaterOrEqual, lessThan Item, f ItemIterator) {
+	t.Iterate(IterOptions{
+		Lower: InclusiveBound(greaterOrEqual),
+		Upper: ExclusiveBound(lessThan),
+	}, f)
 }
 
 // Delete removes an item equal to the passed in item from the tree, returning
 // it. If no such item exists, returns nil.
 func (t *Tree) Delete(item Item) (replaced Item) {
+	t.mutate()
 	n := node{item: item, l: null, r: null, p: null}
 	it := iterator{node: &n}
 	if !t.root.r(t).isRed() && !t.root.l(t).isRed() {
 		t.root.setIsRed(true)
 	}
-	t.root, replaced = t.root.del(t, &it)
+	var rank uint32
+	t.root, replaced, rank = t.root.del(t, &it)
 	t.root.setIsRed(false)
+	if replaced != nil && t.observer != nil {
+		t.observer.OnDelete(replaced, int(rank))
+	}
 	return replaced
 }
 
 // DeleteMin removes the smallest item in the tree and returns it.
 // If no such item exists, returns nil.
 func (t *Tree) DeleteMin() (removed Item) {
+	t.mutate()
+	if t.root.node == nil {
+		return nil
+	}
+	if !t.root.r(t).isRed() && !t.root.l(t).isRed() {
+		t.root.setIsRed(true)
+	}
 	t.root, removed = t.root.delMin(t)
+	t.root.setIsRed(false)
+	if t.observer != nil {
+		t.observer.OnDelete(removed, 0)
+	}
+	return removed
+}
+
+// DeleteIf removes every item for which pred returns true in a single ordered
+// pass over the tree and returns the number of items removed.
+func (t *Tree) DeleteIf(pred func(Item) bool) (removed int) {
+	t.AscendMutable(func(item Item) bool {
+		if pred(item) {
+			t.Delete(item)
+			removed++
+		}
+		return true
+	})
 	return removed
 }
 
@@ -152,42 +228,35 @@ func (t *Tree) DeleteMax() Item {
 // Descend calls the iterator for every value in the tree within the range
 // [last, first], until iterator returns false.
 func (t *Tree) Descend(f ItemIterator) {
-	for it, ok := t.root.max(t); ok && f(it.item); it, ok = it.prev(t) {
-	}
+	t.Iterate(IterOptions{Direction: Descending}, f)
 }
 
 // DescendGreaterThan calls the iterator for every value in the tree within
 // the range (pivot, last], until iterator returns false.
 func (t *Tree) DescendGreaterThan(pivot Item, f ItemIterator) {
-	var limit iterator
-	if ok := limit.seek(t, pivot, seekGT); !ok {
-		return
-	}
-	it, ok := t.root.max(t)
-	for ; ok && f(it.item) && it.np != limit.np; it, ok = it.prev(t) {
-	}
+	t.Iterate(IterOptions{
+		Lower:     ExclusiveBound(pivot),
+		Direction: Descending,
+	}, f)
 }
 
 // DescendLessOrEqual calls the iterator for every value in the tree within the
 // range [pivot, first], until iterator returns false.
 func (t *Tree) DescendLessOrEqual(pivot Item, f ItemIterator) {
-	var it iterator
-	ok := it.seek(t, pivot, seekLTE)
-	for ; ok && f(it.item); it, ok = it.prev(t) {
-	}
+	t.Iterate(IterOptions{
+		Upper:     InclusiveBound(pivot),
+		Direction: Descending,
+	}, f)
 }
 
 // DescendRange calls the iterator for every value in the tree within the range
-// [lessOrEqual, greaterThan), until iterator returns false.
+// (greaterThan, lessOrEqual], in descending order, until iterator returns false.
 func (t *Tree) DescendRange(lessOrEqual, greaterThan Item, f ItemIterator) {
-	var limit iterator
-	if ok := limit.seek(t, greaterThan, seekGT); !ok {
-		return
-	}
-	var it iterator
-	ok := it.seek(t, lessOrEqual, seekLTE)
-	for ; ok && f(it.item) && it.np != limit.np; it, ok = it.prev(t) {
-	}
+	t.Iterate(IterOptions{
+		Lower:     ExclusiveBound(greaterThan),
+		Upper:     InclusiveBound(lessOrEqual),
+		Direction: Descending,
+	}, f)
 }
 
 func (t *Tree) Get(key Item) Item {
@@ -222,9 +291,18 @@ func (t *Tree) Min() Item {
 }
 
 func (t *Tree) ReplaceOrInsert(item Item) (replaced Item) {
+	t.mutate()
 	new := t.alloc(item)
-	t.root, replaced = t.root.add(t, new)
+	var rank uint32
+	t.root, replaced, rank = t.root.add(t, new)
 	t.root.setIsRed(false)
+	if t.observer != nil {
+		if replaced != nil {
+			t.observer.OnReplace(replaced, item, int(rank))
+		} else {
+			t.observer.OnInsert(item, int(rank))
+		}
+	}
 	return replaced
 }
 
@@ -246,16 +324,36 @@ func (p pointer) n(t *Tree) *node {
 const redMask uint32 = 1 << 31
 const countMask uint32 = ^redMask
 
+// mutate is called at the start of every mutation.
+func (t *Tree) mutate() {
+	t.gen++
+	if tr := t.tracking.Load(); tr != nil {
+		t.stamp = tr.clock.Add(1)
+	}
+}
+
+// touch records that the node at it was modified by the current mutation.
+func (t *Tree) touch(it iterator) {
+	if tr := t.tracking.Load(); tr != nil {
+		tr.stamps[it.np] = t.stamp
+	}
+}
+
 func (t *Tree) realloc() {
-	prevLen := len(t.list)
-	var newList []node
-	if prevLen > 0 {
-		newList = make([]node, 2*prevLen)
-		copy(newList, t.list)
-	} else {
-		const defaultSize = 16
-		newList = make([]node, defaultSize)
+	const defaultSize = 16
+	newLen := defaultSize
+	if len(t.list) > 0 {
+		newLen = 2 * len(t.list)
 	}
+	t.resize(newLen)
+}
+
+// resize grows the arena to newLen nodes, all of the new ones free. It must
+// only be called when there are no free nodes.
+func (t *Tree) resize(newLen int) {
+	prevLen := len(t.list)
+	newList := make([]node, newLen)
+	copy(newList, t.list)
 	for i := prevLen + 1; i < len(newList); i++ {
 		newList[i-1] = node{
 			p: null,
@@ -265,6 +363,9 @@ func (t *Tree) realloc() {
 	}
 	newList[len(newList)-1] = node{p: null, l: null, r: null}
 	t.list = newList
+	if tr := t.tracking.Load(); tr != nil {
+		tr.stamps = append(tr.stamps, make([]uint64, newLen-len(tr.stamps))...)
+	}
 	t.fp.init(t, pointer(prevLen))
 	t.root.init(t, t.root.np)
 }
@@ -276,6 +377,7 @@ func (t *Tree) alloc(item Item) (it iterator) {
 	it = t.fp
 	t.fp = it.r(t)
 	*it.node = node{item: item, p: null, l: null, r: null, c: redMask}
+	t.touch(it)
 	return it
 }
 
@@ -419,6 +521,19 @@ func (it iterator) prev(t *Tree) (next iterator, ok bool) {
 	return p, p.node != nil
 }
 
+// rank returns the number of items in the tree which precede it.
+func (it iterator) rank(t *Tree) int {
+	rank := it.l(t).count()
+	p := it.p(t)
+	for p.node != nil {
+		if p.node.r == it.np {
+			rank += p.l(t).count() + 1
+		}
+		it, p = p, p.p(t)
+	}
+	return int(rank)
+}
+
 func (it iterator) setRight(r iterator) {
 	if it.node == nil {
 		return
@@ -440,6 +555,7 @@ func (it iterator) setLeft(l iterator) {
 }
 
 func (it iterator) fixUp(t *Tree) (ret iterator) {
+	t.touch(it)
 	if it.r(t).isRed() {
 		it = it.rotateLeft(t)
 	}
@@ -454,65 +570,83 @@ func (it iterator) fixUp(t *Tree) (ret iterator) {
 	return it
 }
 
-func (it iterator) add(t *Tree, toAdd iterator) (ret iterator, replaced Item) {
+// add inserts toAdd into the subtree rooted at it, returning the new root of
+// the subtree, the item which was replaced if any and the rank of the added
+// item within the subtree.
+func (it iterator) add(t *Tree, toAdd iterator) (ret iterator, replaced Item, rank uint32) {
 	if it.node == nil {
 		toAdd.setIsRed(true)
-		return toAdd.fixUp(t), nil
+		return toAdd.fixUp(t), nil, 0
 	}
-	switch {
-	case toAdd.item.Less(it.item):
+	switch c := t.cmp(toAdd.item, it.item); {
+	case c < 0:
 		var l iterator
-		l, replaced = it.l(t).add(t, toAdd)
+		l, replaced, rank = it.l(t).add(t, toAdd)
 		it.setLeft(l)
-	case it.item.Less(toAdd.item):
+	case c > 0:
+		below := it.l(t).count() + 1
 		var r iterator
-		r, replaced = it.r(t).add(t, toAdd)
+		r, replaced, rank = it.r(t).add(t, toAdd)
+		rank += below
 		it.setRight(r)
 	default:
 		replaced = it.item
 		it.item = toAdd.item
+		t.touch(it)
 		t.free(toAdd)
-		return it, replaced
+		return it, replaced, it.l(t).count()
 	}
 
-	return it.fixUp(t), replaced
+	return it.fixUp(t), replaced, rank
 }
 
-func (it iterator) del(t *Tree, toDel *iterator) (_ iterator, replaced Item) {
+// del removes the item equal to toDel from the subtree rooted at it,
+// returning the new root of the subtree, the removed item if any and the rank
+// the removed item had within the subtree.
+func (it iterator) del(t *Tree, toDel *iterator) (_ iterator, replaced Item, rank uint32) {
 	if it.node == nil {
-		return iterator{np: null}, nil
+		return iterator{np: null}, nil, 0
 	}
-	if less := toDel.item.Less(it.item); less {
+	if c := t.cmp(toDel.item, it.item); c < 0 {
+		if !it.hasLeft() {
+			// The item is not in the tree. Return before moveRedLeft so that
+			// no colors are changed below the last rebalanced node.
+			return it, nil, 0
+		}
 		if l := it.l(t); !l.isRed() && !l.l(t).isRed() {
 			it = it.moveRedLeft(t)
 		}
 		var l iterator
-		l, replaced = it.l(t).del(t, toDel)
+		l, replaced, rank = it.l(t).del(t, toDel)
 		it.setLeft(l)
 	} else {
 		if it.l(t).isRed() {
 			it = it.rotateRight(t)
+			c = t.cmp(toDel.item, it.item)
 		}
-		if less = toDel.item.Less(it.item); !less && !it.item.Less(toDel.item) && !it.hasRight() {
-			replaced = it.item
+		if c == 0 && !it.hasRight() {
+			replaced, rank = it.item, it.l(t).count()
 			t.free(it)
-			return iterator{np: null}, replaced
+			return iterator{np: null}, replaced, rank
 		}
-		if r := it.r(t); !r.isRed() && !r.l(t).isRed() {
+		if r := it.r(t); r.node != nil && !r.isRed() && !r.l(t).isRed() {
 			it = it.moveRedRight(t)
+			c = t.cmp(toDel.item, it.item)
 		}
-		if !toDel.item.Less(it.item) && !it.item.Less(toDel.item) {
+		below := it.l(t).count()
+		if c == 0 {
 			r := it.r(t)
-			replaced = it.item
+			replaced, rank = it.item, below
 			r, it.item = r.delMin(t)
 			it.setRight(r)
 		} else {
 			var r iterator
-			r, replaced = it.r(t).del(t, toDel)
+			r, replaced, rank = it.r(t).del(t, toDel)
+			rank += below + 1
 			it.setRight(r)
 		}
 	}
-	return it.fixUp(t), replaced
+	return it.fixUp(t), replaced, rank
 }
 
 func (it iterator) delMin(t *Tree) (ret iterator, removed Item) {
@@ -553,6 +687,8 @@ func (it iterator) rotateRight(t *Tree) (ret iterator) {
 	x.node.p = null
 	x.setCount(it.count())
 	it.setCount(it.l(t).count() + it.r(t).count() + 1)
+	t.touch(it)
+	t.touch(x)
 	return x
 }
 
@@ -568,6 +704,8 @@ func (it iterator) rotateLeft(t *Tree) (ret iterator) {
 	x.node.p = null
 	x.setCount(it.count())
 	it.setCount(it.l(t).count() + it.r(t).count() + 1)
+	t.touch(it)
+	t.touch(x)
 	return x
 }
 
@@ -614,8 +752,8 @@ const (
 func (it *iterator) seek(t *Tree, item Item, mode seekMode) (ok bool) {
 	*it = t.root
 	for it.node != nil {
-		switch {
-		case item.Less(it.item):
+		switch c := t.cmp(item, it.item); {
+		case c < 0:
 			l := it.l(t)
 			if l.node == nil {
 				switch mode {
@@ -627,7 +765,7 @@ func (it *iterator) seek(t *Tree, item Item, mode seekMode) (ok bool) {
 				}
 			}
 			*it = l
-		case it.item.Less(item):
+		case c > 0:
 			r := it.r(t)
 			if r.node == nil {
 				switch mode {
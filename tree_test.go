@@ -1,6 +1,7 @@
 package orderstat
 
 import (
+	"fmt"
 	"math/rand"
 	"strconv"
 	"testing"
@@ -97,6 +98,143 @@ func TestTree(t *testing.T) {
 	}
 }
 
+func TestAscendMutable(t *testing.T) {
+	tr := NewTree()
+	const N = 1000
+	for i := 0; i < N; i += 2 {
+		tr.ReplaceOrInsert(intItem(i))
+	}
+	// Delete the visited item, insert an item behind the iteration point and
+	// insert the odd item just ahead of it. Only the even items originally in
+	// the tree and the odd items inserted ahead should be visited.
+	var seen []int
+	tr.AscendMutable(func(item Item) bool {
+		i := int(item.(intItem))
+		seen = append(seen, i)
+		if i%2 == 0 {
+			assert.NotNil(t, tr.Delete(item))
+			tr.ReplaceOrInsert(intItem(-i - 1))
+			tr.ReplaceOrInsert(intItem(i + 1))
+		}
+		assert.Nil(t, tr.Validate())
+		return true
+	})
+	for i := range seen {
+		assert.Equal(t, i, seen[i])
+	}
+	assert.Equal(t, N, len(seen))
+	assert.Equal(t, N, tr.Len())
+	assert.Equal(t, intItem(-N+1), tr.Min())
+	assert.Equal(t, intItem(N-1), tr.Max())
+
+	seen = seen[:0]
+	tr.AscendMutable(func(item Item) bool {
+		seen = append(seen, int(item.(intItem)))
+		tr.DeleteMin()
+		return len(seen) < 3
+	})
+	assert.Equal(t, []int{-N + 1, -N + 3, -N + 5}, seen)
+}
+
+func TestDeleteIf(t *testing.T) {
+	tr := NewTree()
+	const N = 1000
+	for _, i := range rand.Perm(N) {
+		tr.ReplaceOrInsert(intItem(i))
+	}
+	removed := tr.DeleteIf(func(item Item) bool {
+		return item.(intItem)%3 != 0
+	})
+	assert.Equal(t, N-(N+2)/3, removed)
+	assert.Equal(t, (N+2)/3, tr.Len())
+	assert.Nil(t, tr.Validate())
+	for i := 0; i < tr.Len(); i++ {
+		assert.Equal(t, intItem(3*i), tr.Select(i))
+	}
+	assert.Equal(t, 0, tr.DeleteIf(func(Item) bool { return false }))
+	assert.Equal(t, tr.Len(), tr.DeleteIf(func(Item) bool { return true }))
+	assert.Equal(t, 0, tr.Len())
+}
+
+// TestDeleteAbsent deletes items which may not be in the tree, which used to
+// corrupt the tree by recoloring nodes below the point where the search
+// ended.
+func TestDeleteAbsent(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	tr := NewTree()
+	m := map[int]bool{}
+	for i := 0; i < 10000; i++ {
+		k := rng.Intn(100)
+		switch rng.Intn(4) {
+		case 0, 1:
+			tr.ReplaceOrInsert(intItem(k))
+			m[k] = true
+		case 2:
+			assert.Equal(t, m[k], tr.Delete(intItem(k)) != nil)
+			delete(m, k)
+		case 3:
+			if min := tr.DeleteMin(); min != nil {
+				delete(m, int(min.(intItem)))
+			}
+		}
+		if !assert.Equal(t, len(m), tr.Len()) || !assert.Nil(t, tr.Validate()) {
+			return
+		}
+	}
+	for tr.Len() > 0 {
+		tr.DeleteMin()
+	}
+	assert.Nil(t, tr.DeleteMin())
+	assert.Nil(t, tr.DeleteMax())
+}
+
+type player struct {
+	name  string
+	score int
+}
+
+func (p player) Less(other Item) bool {
+	return p.name < other.(player).name
+}
+
+// TestNewTreeWithLess keeps two trees over the same items ordered by
+// different fields.
+func TestNewTreeWithLess(t *testing.T) {
+	byName := NewTree()
+	byScore := NewTreeWithLess(func(a, b Item) bool {
+		ap, bp := a.(player), b.(player)
+		if ap.score != bp.score {
+			return ap.score > bp.score
+		}
+		return ap.name < bp.name
+	})
+	for _, i := range rand.Perm(100) {
+		p := player{name: fmt.Sprintf("p%02d", i), score: i % 10}
+		byName.ReplaceOrInsert(p)
+		byScore.ReplaceOrInsert(p)
+	}
+	assert.Nil(t, byName.Validate())
+	assert.Nil(t, byScore.Validate())
+	assert.Equal(t, player{"p00", 0}, byName.Min())
+	assert.Equal(t, player{"p09", 9}, byScore.Min())
+	assert.Equal(t, player{"p90", 0}, byScore.Max())
+	assert.Equal(t, 42, byName.Rank(player{"p42", 2}))
+	assert.Equal(t, 74, byScore.Rank(player{"p42", 2}))
+	item, rank := byScore.Floor(player{"zzz", 5})
+	assert.Equal(t, player{"p95", 5}, item)
+	assert.Equal(t, 49, rank)
+
+	// Equality is decided by the comparator rather than the whole item.
+	assert.Equal(t, player{"p07", 7}, byName.ReplaceOrInsert(player{"p07", 11}))
+	assert.Equal(t, player{"p07", 11}, byName.Get(player{name: "p07"}))
+	assert.Nil(t, byScore.Get(player{name: "p07"}))
+	assert.Equal(t, player{"p07", 7}, byScore.Delete(player{"p07", 7}))
+	assert.Nil(t, byScore.Delete(player{"p07", 7}))
+	assert.Equal(t, 100, byName.Len())
+	assert.Equal(t, 99, byScore.Len())
+	assert.Nil(t, byScore.Validate())
+}
+
 // // func TestRandom(t *testing.T) {
 // // 	const N = 4096
 // // 	m := make(map[float64]float64)
@@ -0,0 +1,95 @@
+package orderstat
+
+import "fmt"
+
+// Validate checks the structural invariants of the tree, returning an error
+// describing the first violation found. It verifies that items are ordered,
+// that subtree counts and parent pointers are consistent, and that the tree
+// satisfies the left-leaning red-black properties. It takes time linear in the
+// size of the tree and is intended for use in tests.
+func (t *Tree) Validate() error {
+	if t.root.node == nil {
+		return nil
+	}
+	if t.root.node.p != null {
+		return fmt.Errorf("root %v has parent %v", t.root, t.root.node.p)
+	}
+	if t.root.isRed() {
+		return fmt.Errorf("root %v is red", t.root)
+	}
+	if err := t.isBST(); err != nil {
+		return err
+	}
+	_, err := t.root.isLLRB(t)
+	return err
+}
+
+func (t *Tree) isBST() error {
+	return t.root.isBST(t, nil, nil)
+}
+
+func (it *iterator) isBST(t *Tree, min, max Item) error {
+	if it.node == nil {
+		return nil
+	}
+	if min != nil && t.less(it.item, min) {
+		return fmt.Errorf("key %v < min %v", it.item, min)
+	}
+	if max != nil && t.less(max, it.item) {
+		return fmt.Errorf("key %v > max %v", it.item, max)
+	}
+	l := it.l(t)
+	if l.node != nil && t.less(it.item, l.item) {
+		return fmt.Errorf("parent key %v < left child key %v", it.item, l.item)
+	}
+	r := it.r(t)
+	if r.node != nil && t.less(r.item, it.item) {
+		return fmt.Errorf("parent key (%v) %v > right child key (%v)", it.np, it.item, r.np)
+	}
+	if err := l.isBST(t, min, it.item); err != nil {
+		return err
+	}
+	if err := r.isBST(t, it.item, max); err != nil {
+		return err
+	}
+	if lc, rc, ic := l.count(), r.count(), it.count(); ic != lc+rc+1 {
+		return fmt.Errorf("count is not equal: %v + %v + 1 != %v", lc, rc, ic)
+	}
+	return nil
+}
+
+// isLLRB checks the parent pointers and coloring of the subtree rooted at it
+// and returns its black height.
+func (it iterator) isLLRB(t *Tree) (blackHeight int, err error) {
+	if it.node == nil {
+		return 0, nil
+	}
+	l, r := it.l(t), it.r(t)
+	if l.node != nil && l.node.p != it.np {
+		return 0, fmt.Errorf("left child %v of %v has parent %v", l, it, l.node.p)
+	}
+	if r.node != nil && r.node.p != it.np {
+		return 0, fmt.Errorf("right child %v of %v has parent %v", r, it, r.node.p)
+	}
+	if r.isRed() {
+		return 0, fmt.Errorf("right child %v of %v is red", r, it)
+	}
+	if it.isRed() && l.isRed() {
+		return 0, fmt.Errorf("red node %v has red left child %v", it, l)
+	}
+	lh, err := l.isLLRB(t)
+	if err != nil {
+		return 0, err
+	}
+	rh, err := r.isLLRB(t)
+	if err != nil {
+		return 0, err
+	}
+	if lh != rh {
+		return 0, fmt.Errorf("black height of %v differs: %d != %d", it, lh, rh)
+	}
+	if !it.isRed() {
+		lh++
+	}
+	return lh, nil
+}
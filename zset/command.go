@@ -0,0 +1,586 @@
+package zset
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// DB
+////////////////////////////////////////////////////////////////////////////////
+
+// DB is a collection of Sets addressed by key which executes Redis commands.
+//
+// DB is safe for concurrent use by multiple goroutines. Every command is
+// executed atomically.
+type DB struct {
+	mu   sync.Mutex
+	sets map[string]*Set
+}
+
+// NewDB creates a new, empty DB.
+func NewDB() *DB {
+	return &DB{sets: make(map[string]*Set)}
+}
+
+// SimpleString is a reply which is sent as a RESP simple string.
+type SimpleString string
+
+// Error is a reply which is sent as a RESP error.
+type Error string
+
+func (e Error) Error() string { return string(e) }
+
+// Do executes the command in args and returns its reply. The reply is one of:
+//
+//   - SimpleString, for status replies such as OK
+//   - Error, for errors
+//   - int64, for integer replies
+//   - string, for bulk string replies
+//   - nil, for the null reply
+//   - []any, for array replies whose elements are themselves replies
+//
+// A command which refers to a key holding no members behaves as though the
+// key holds an empty set, and a set is removed from the DB once it is
+// emptied, as in Redis.
+func (db *DB) Do(args ...string) (reply any) {
+	if len(args) == 0 {
+		return Error("ERR empty command")
+	}
+	name := strings.ToLower(args[0])
+	cmd, ok := commands[name]
+	if !ok {
+		return Error(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+	}
+	if len(args) < cmd.minArgs || (cmd.maxArgs > 0 && len(args) > cmd.maxArgs) {
+		return Error(fmt.Sprintf("ERR wrong number of arguments for '%s' command", name))
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	defer func() {
+		// Drop the key if the command emptied its set.
+		if len(args) > 1 {
+			if s, ok := db.sets[args[1]]; ok && s.Len() == 0 {
+				delete(db.sets, args[1])
+			}
+		}
+	}()
+	r, err := cmd.fn(db, args[1:])
+	if err != nil {
+		var e Error
+		if errors.As(err, &e) {
+			return e
+		}
+		return Error("ERR " + err.Error())
+	}
+	return r
+}
+
+// get returns the set stored at key, creating it if create is true. If the
+// key does not exist and create is false, it returns an empty set which is
+// not stored in the DB.
+func (db *DB) get(key string, create bool) *Set {
+	s, ok := db.sets[key]
+	if !ok {
+		s = New()
+		if create {
+			db.sets[key] = s
+		}
+	}
+	return s
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Commands
+////////////////////////////////////////////////////////////////////////////////
+
+type command struct {
+	// minArgs and maxArgs bound the number of arguments including the
+	// command name. A maxArgs of zero means that there is no upper bound.
+	minArgs, maxArgs int
+	fn               func(db *DB, args []string) (any, error)
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"ping":             {1, 2, ping},
+		"echo":             {2, 2, echo},
+		"select":           {2, 2, selectDB},
+		"client":           {2, 0, okReply},
+		"flushdb":          {1, 2, flush},
+		"flushall":         {1, 2, flush},
+		"del":              {2, 0, del},
+		"exists":           {2, 0, exists},
+		"zadd":             {4, 0, zadd},
+		"zincrby":          {4, 4, zincrby},
+		"zrem":             {3, 0, zrem},
+		"zscore":           {3, 3, zscore},
+		"zrank":            {3, 4, zrank(false)},
+		"zrevrank":         {3, 4, zrank(true)},
+		"zcard":            {2, 2, zcard},
+		"zcount":           {4, 4, zcount},
+		"zlexcount":        {4, 4, zlexcount},
+		"zrange":           {4, 0, zrange(rangeByRank, false)},
+		"zrevrange":        {4, 5, zrange(rangeByRank, true)},
+		"zrangebyscore":    {4, 0, zrange(rangeByScore, false)},
+		"zrevrangebyscore": {4, 0, zrange(rangeByScore, true)},
+		"zrangebylex":      {4, 0, zrange(rangeByLex, false)},
+		"zrevrangebylex":   {4, 0, zrange(rangeByLex, true)},
+		"zpopmin":          {2, 3, zpop(false)},
+		"zpopmax":          {2, 3, zpop(true)},
+		"zremrangebyrank":  {4, 4, zremrangebyrank},
+	}
+}
+
+var (
+	errSyntax     = Error("ERR syntax error")
+	errNotInteger = Error("ERR value is not an integer or out of range")
+	errNotFloat   = Error("ERR value is not a valid float")
+	errNaN        = Error("ERR resulting score is not a number (NaN)")
+	errScoreRange = Error("ERR min or max is not a float")
+	errLexRange   = Error("ERR min or max not valid string range item")
+)
+
+func ping(db *DB, args []string) (any, error) {
+	if len(args) == 1 {
+		return args[0], nil
+	}
+	return SimpleString("PONG"), nil
+}
+
+func echo(db *DB, args []string) (any, error) {
+	return args[0], nil
+}
+
+func selectDB(db *DB, args []string) (any, error) {
+	if args[0] != "0" {
+		return nil, Error("ERR DB index is out of range")
+	}
+	return SimpleString("OK"), nil
+}
+
+func okReply(db *DB, args []string) (any, error) {
+	return SimpleString("OK"), nil
+}
+
+func flush(db *DB, args []string) (any, error) {
+	db.sets = make(map[string]*Set)
+	return SimpleString("OK"), nil
+}
+
+func del(db *DB, args []string) (any, error) {
+	var n int64
+	for _, key := range args {
+		if _, ok := db.sets[key]; ok {
+			delete(db.sets, key)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func exists(db *DB, args []string) (any, error) {
+	var n int64
+	for _, key := range args {
+		if _, ok := db.sets[key]; ok {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// zadd implements ZADD key [NX|XX] [GT|LT] [CH] [INCR] score member [score member ...].
+func zadd(db *DB, args []string) (any, error) {
+	key, args := args[0], args[1:]
+	var nx, xx, gt, lt, ch, incr bool
+flags:
+	for len(args) > 0 {
+		switch strings.ToLower(args[0]) {
+		case "nx":
+			nx = true
+		case "xx":
+			xx = true
+		case "gt":
+			gt = true
+		case "lt":
+			lt = true
+		case "ch":
+			ch = true
+		case "incr":
+			incr = true
+		default:
+			break flags
+		}
+		args = args[1:]
+	}
+	if len(args) == 0 || len(args)%2 != 0 {
+		return nil, errSyntax
+	}
+	if nx && xx {
+		return nil, Error("ERR XX and NX options at the same time are not compatible")
+	}
+	if (gt && lt) || (nx && (gt || lt)) {
+		return nil, Error("ERR GT, LT, and/or NX options at the same time are not compatible")
+	}
+	if incr && len(args) != 2 {
+		return nil, Error("ERR INCR option supports a single increment-element pair")
+	}
+	scores := make([]float64, len(args)/2)
+	for i := range scores {
+		var err error
+		if scores[i], err = parseFloat(args[2*i]); err != nil {
+			return nil, errNotFloat
+		}
+	}
+	s := db.get(key, true)
+	var added, changed int64
+	for i, score := range scores {
+		member := args[2*i+1]
+		prev, exists := s.Score(member)
+		if (nx && exists) || (xx && !exists) {
+			if incr {
+				return nil, nil
+			}
+			continue
+		}
+		if incr {
+			score += prev
+			if math.IsNaN(score) {
+				return nil, errNaN
+			}
+		}
+		if exists && ((gt && score <= prev) || (lt && score >= prev)) {
+			if incr {
+				return nil, nil
+			}
+			continue
+		}
+		if !exists {
+			added++
+		} else if score != prev {
+			changed++
+		}
+		s.Add(member, score)
+		if incr {
+			return formatFloat(score), nil
+		}
+	}
+	if ch {
+		return added + changed, nil
+	}
+	return added, nil
+}
+
+func zincrby(db *DB, args []string) (any, error) {
+	delta, err := parseFloat(args[1])
+	if err != nil {
+		return nil, errNotFloat
+	}
+	s := db.get(args[0], true)
+	prev, _ := s.Score(args[2])
+	score := prev + delta
+	if math.IsNaN(score) {
+		return nil, errNaN
+	}
+	s.Add(args[2], score)
+	return formatFloat(score), nil
+}
+
+func zrem(db *DB, args []string) (any, error) {
+	s := db.get(args[0], false)
+	var n int64
+	for _, member := range args[1:] {
+		if s.Remove(member) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func zscore(db *DB, args []string) (any, error) {
+	if score, ok := db.get(args[0], false).Score(args[1]); ok {
+		return formatFloat(score), nil
+	}
+	return nil, nil
+}
+
+// zrank implements ZRANK and ZREVRANK key member [WITHSCORE].
+func zrank(rev bool) func(db *DB, args []string) (any, error) {
+	return func(db *DB, args []string) (any, error) {
+		withScore := len(args) == 3
+		if withScore && !strings.EqualFold(args[2], "withscore") {
+			return nil, errSyntax
+		}
+		s := db.get(args[0], false)
+		rank := s.Rank(args[1])
+		if rank < 0 {
+			return nil, nil
+		}
+		if rev {
+			rank = s.Len() - 1 - rank
+		}
+		if withScore {
+			score, _ := s.Score(args[1])
+			return []any{int64(rank), formatFloat(score)}, nil
+		}
+		return int64(rank), nil
+	}
+}
+
+func zcard(db *DB, args []string) (any, error) {
+	return int64(db.get(args[0], false).Len()), nil
+}
+
+func zcount(db *DB, args []string) (any, error) {
+	lower, upper, err := parseScoreRange(args[1], args[2])
+	if err != nil {
+		return nil, err
+	}
+	lo, hi := db.get(args[0], false).ScoreRange(lower, upper)
+	return int64(hi - lo), nil
+}
+
+func zlexcount(db *DB, args []string) (any, error) {
+	lower, upper, err := parseLexRange(args[1], args[2])
+	if err != nil {
+		return nil, err
+	}
+	lo, hi := db.get(args[0], false).LexRange(lower, upper)
+	return int64(hi - lo), nil
+}
+
+type rangeKind int
+
+const (
+	rangeByRank rangeKind = iota
+	rangeByScore
+	rangeByLex
+)
+
+// zrange implements ZRANGE key start stop [BYSCORE|BYLEX] [REV] [LIMIT offset
+// count] [WITHSCORES] along with the legacy ZREVRANGE, ZRANGEBYSCORE,
+// ZREVRANGEBYSCORE, ZRANGEBYLEX and ZREVRANGEBYLEX, which are expressed as
+// ZRANGE with the kind and direction fixed.
+func zrange(kind rangeKind, rev bool) func(db *DB, args []string) (any, error) {
+	legacy := kind != rangeByRank || rev
+	return func(db *DB, args []string) (any, error) {
+		kind, rev := kind, rev
+		key, start, stop := args[0], args[1], args[2]
+		var withScores, limited bool
+		offset, count := 0, -1
+		for opts := args[3:]; len(opts) > 0; opts = opts[1:] {
+			switch opt := strings.ToLower(opts[0]); {
+			case opt == "withscores":
+				withScores = true
+			case opt == "byscore" && !legacy && kind == rangeByRank:
+				kind = rangeByScore
+			case opt == "bylex" && !legacy && kind == rangeByRank:
+				kind = rangeByLex
+			case opt == "rev" && !legacy:
+				rev = true
+			case opt == "limit" && len(opts) >= 3 && !(legacy && kind == rangeByRank):
+				var err1, err2 error
+				offset, err1 = strconv.Atoi(opts[1])
+				count, err2 = strconv.Atoi(opts[2])
+				if err1 != nil || err2 != nil {
+					return nil, errNotInteger
+				}
+				limited = true
+				opts = opts[2:]
+			default:
+				return nil, errSyntax
+			}
+		}
+		if limited && kind == rangeByRank {
+			return nil, Error("ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+		}
+		if withScores && kind == rangeByLex {
+			return nil, Error("ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+		}
+		s := db.get(key, false)
+		var lo, hi int
+		switch kind {
+		case rangeByRank:
+			first, err1 := strconv.Atoi(start)
+			last, err2 := strconv.Atoi(stop)
+			if err1 != nil || err2 != nil {
+				return nil, errNotInteger
+			}
+			if rev {
+				// Ranks count from the highest score.
+				first, last = s.Len()-1-normalizeRank(last, s.Len()), s.Len()-1-normalizeRank(first, s.Len())
+			} else {
+				first, last = normalizeRank(first, s.Len()), normalizeRank(last, s.Len())
+			}
+			lo, hi = first, last+1
+		case rangeByScore:
+			if rev {
+				start, stop = stop, start
+			}
+			lower, upper, err := parseScoreRange(start, stop)
+			if err != nil {
+				return nil, err
+			}
+			lo, hi = s.ScoreRange(lower, upper)
+		case rangeByLex:
+			if rev {
+				start, stop = stop, start
+			}
+			lower, upper, err := parseLexRange(start, stop)
+			if err != nil {
+				return nil, err
+			}
+			lo, hi = s.LexRange(lower, upper)
+		}
+		if limited {
+			if offset < 0 {
+				return []any{}, nil
+			}
+			if rev {
+				hi -= offset
+				if count >= 0 {
+					lo = max(lo, hi-count)
+				}
+			} else {
+				lo += offset
+				if count >= 0 {
+					hi = min(hi, lo+count)
+				}
+			}
+		}
+		reply := []any{}
+		for _, e := range s.Range(lo, hi, rev) {
+			reply = append(reply, e.Member)
+			if withScores {
+				reply = append(reply, formatFloat(e.Score))
+			}
+		}
+		return reply, nil
+	}
+}
+
+// zpop implements ZPOPMIN and ZPOPMAX key [count].
+func zpop(highest bool) func(db *DB, args []string) (any, error) {
+	return func(db *DB, args []string) (any, error) {
+		count := 1
+		if len(args) == 2 {
+			var err error
+			if count, err = strconv.Atoi(args[1]); err != nil || count < 0 {
+				return nil, Error("ERR value is out of range, must be positive")
+			}
+		}
+		s := db.get(args[0], false)
+		var popped []Entry
+		if highest {
+			popped = s.PopMax(count)
+		} else {
+			popped = s.PopMin(count)
+		}
+		reply := []any{}
+		for _, e := range popped {
+			reply = append(reply, e.Member, formatFloat(e.Score))
+		}
+		return reply, nil
+	}
+}
+
+func zremrangebyrank(db *DB, args []string) (any, error) {
+	first, err1 := strconv.Atoi(args[1])
+	last, err2 := strconv.Atoi(args[2])
+	if err1 != nil || err2 != nil {
+		return nil, errNotInteger
+	}
+	s := db.get(args[0], false)
+	first, last = normalizeRank(first, s.Len()), normalizeRank(last, s.Len())
+	return int64(s.RemoveRange(first, last+1)), nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Parsing and formatting
+////////////////////////////////////////////////////////////////////////////////
+
+// normalizeRank converts a rank which may count back from the end of a set of
+// n members, as -1 does for the last member, into a rank counting from the
+// start. Ranks beyond either end are clamped to -1 or n.
+func normalizeRank(rank, n int) int {
+	if rank < 0 {
+		rank += n
+	}
+	return max(-1, min(rank, n))
+}
+
+// parseFloat parses a score as Redis does, accepting inf, +inf and -inf in
+// any case and rejecting NaN.
+func parseFloat(s string) (float64, error) {
+	switch strings.ToLower(s) {
+	case "inf", "+inf":
+		return math.Inf(1), nil
+	case "-inf":
+		return math.Inf(-1), nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, errNotFloat
+	}
+	return f, nil
+}
+
+// parseScoreRange parses the bounds of a score range, each of which is a
+// score optionally preceded by "(" to make it exclusive.
+func parseScoreRange(min, max string) (lower, upper ScoreBound, err error) {
+	parse := func(s string) (b ScoreBound, err error) {
+		if strings.HasPrefix(s, "(") {
+			b.Exclusive, s = true, s[1:]
+		}
+		if b.Score, err = parseFloat(s); err != nil {
+			return b, errScoreRange
+		}
+		return b, nil
+	}
+	if lower, err = parse(min); err != nil {
+		return lower, upper, err
+	}
+	upper, err = parse(max)
+	return lower, upper, err
+}
+
+// parseLexRange parses the bounds of a lexicographical range, each of which
+// is "-", "+" or a member preceded by "[" if inclusive or "(" if exclusive.
+func parseLexRange(min, max string) (lower, upper LexBound, err error) {
+	parse := func(s string) (LexBound, error) {
+		switch {
+		case s == "-":
+			return LexBound{Infinite: -1}, nil
+		case s == "+":
+			return LexBound{Infinite: 1}, nil
+		case strings.HasPrefix(s, "["):
+			return LexBound{Member: s[1:]}, nil
+		case strings.HasPrefix(s, "("):
+			return LexBound{Member: s[1:], Exclusive: true}, nil
+		default:
+			return LexBound{}, errLexRange
+		}
+	}
+	if lower, err = parse(min); err != nil {
+		return lower, upper, err
+	}
+	upper, err = parse(max)
+	return lower, upper, err
+}
+
+// formatFloat formats a score as Redis does.
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,176 @@
+package zset
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCommands runs sequences of commands, most of them taken from the
+// examples in the Redis documentation, and checks each reply.
+func TestCommands(t *testing.T) {
+	type step struct {
+		cmd  string
+		want any
+	}
+	for _, c := range []struct {
+		name  string
+		steps []step
+	}{
+		{"zadd", []step{
+			{"ZADD myzset 1 one", int64(1)},
+			{"ZADD myzset 1 uno", int64(1)},
+			{"ZADD myzset 2 two 3 three", int64(2)},
+			{"ZRANGE myzset 0 -1 WITHSCORES", []any{"one", "1", "uno", "1", "two", "2", "three", "3"}},
+			{"ZADD myzset NX 10 one 4 four", int64(1)},
+			{"ZADD myzset XX CH 10 one 5 five", int64(1)},
+			{"ZADD myzset GT CH 5 one 20 two", int64(1)},
+			{"ZADD myzset LT 1 uno", int64(0)},
+			{"ZSCORE myzset one", "10"},
+			{"ZSCORE myzset two", "20"},
+			{"ZSCORE myzset five", nil},
+			{"ZADD myzset INCR 2.5 one", "12.5"},
+			{"ZADD myzset NX INCR 2.5 one", nil},
+			{"ZADD myzset XX NX 1 one", Error("ERR XX and NX options at the same time are not compatible")},
+			{"ZADD myzset GT LT 1 one", Error("ERR GT, LT, and/or NX options at the same time are not compatible")},
+			{"ZADD myzset 1 one 2", Error("ERR syntax error")},
+			{"ZADD myzset foo one", Error("ERR value is not a valid float")},
+			{"ZADD myzset nan one", Error("ERR value is not a valid float")},
+			{"ZADD myzset -inf neg +inf pos", int64(2)},
+			{"ZRANGE myzset 0 -1 WITHSCORES", []any{
+				"neg", "-inf", "uno", "1", "three", "3", "four", "4", "one", "12.5", "two", "20", "pos", "inf",
+			}},
+			{"ZINCRBY myzset 0.5 uno", "1.5"},
+			{"ZINCRBY myzset inf pos", "inf"},
+			{"ZINCRBY myzset -inf pos", Error("ERR resulting score is not a number (NaN)")},
+		}},
+		{"zrem", []step{
+			{"ZADD myzset 1 one 2 two 3 three", int64(3)},
+			{"ZREM myzset two four", int64(1)},
+			{"ZRANGE myzset 0 -1 WITHSCORES", []any{"one", "1", "three", "3"}},
+			{"ZREM myzset one three", int64(2)},
+			{"EXISTS myzset", int64(0)},
+			{"ZREM myzset one", int64(0)},
+			{"ZCARD myzset", int64(0)},
+		}},
+		{"zrank", []step{
+			{"ZADD myzset 1 one 2 two 3 three", int64(3)},
+			{"ZRANK myzset three", int64(2)},
+			{"ZRANK myzset four", nil},
+			{"ZRANK myzset three WITHSCORE", []any{int64(2), "3"}},
+			{"ZREVRANK myzset one", int64(2)},
+			{"ZREVRANK myzset three", int64(0)},
+			{"ZREVRANK myzset one WITHSCORE", []any{int64(2), "1"}},
+			{"ZREVRANK myzset four", nil},
+			{"ZRANK myzset one WITHSCORES", Error("ERR syntax error")},
+		}},
+		{"zrange", []step{
+			{"ZADD myzset 1 one 2 two 3 three", int64(3)},
+			{"ZRANGE myzset 0 -1", []any{"one", "two", "three"}},
+			{"ZRANGE myzset 2 3", []any{"three"}},
+			{"ZRANGE myzset -2 -1", []any{"two", "three"}},
+			{"ZRANGE myzset -100 100", []any{"one", "two", "three"}},
+			{"ZRANGE myzset 5 10", []any{}},
+			{"ZRANGE myzset 0 1 WITHSCORES", []any{"one", "1", "two", "2"}},
+			{"ZRANGE myzset 0 0 REV", []any{"three"}},
+			{"ZRANGE myzset -1 -1 REV", []any{"one"}},
+			{"ZREVRANGE myzset 0 -1", []any{"three", "two", "one"}},
+			{"ZREVRANGE myzset 2 3", []any{"one"}},
+			{"ZREVRANGE myzset -2 -1", []any{"two", "one"}},
+			{"ZRANGE myzset (1 +inf BYSCORE LIMIT 1 1", []any{"three"}},
+			{"ZRANGE myzset +inf (1 BYSCORE REV", []any{"three", "two"}},
+			{"ZRANGE myzset +inf -inf BYSCORE REV LIMIT 1 5", []any{"two", "one"}},
+			{"ZRANGE myzset -inf +inf BYSCORE LIMIT 0 -1", []any{"one", "two", "three"}},
+			{"ZRANGE myzset -inf +inf BYSCORE LIMIT -1 1", []any{}},
+			{"ZRANGEBYSCORE myzset -inf +inf", []any{"one", "two", "three"}},
+			{"ZRANGEBYSCORE myzset 1 2", []any{"one", "two"}},
+			{"ZRANGEBYSCORE myzset (1 2", []any{"two"}},
+			{"ZRANGEBYSCORE myzset (1 (2", []any{}},
+			{"ZRANGEBYSCORE myzset 1 +inf WITHSCORES LIMIT 1 1", []any{"two", "2"}},
+			{"ZREVRANGEBYSCORE myzset +inf -inf", []any{"three", "two", "one"}},
+			{"ZREVRANGEBYSCORE myzset 2 1", []any{"two", "one"}},
+			{"ZREVRANGEBYSCORE myzset 2 (1", []any{"two"}},
+			{"ZREVRANGEBYSCORE myzset (2 (1", []any{}},
+			{"ZRANGE myzset 0 -1 LIMIT 0 1", Error("ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")},
+			{"ZRANGE myzset a 1", Error("ERR value is not an integer or out of range")},
+			{"ZRANGEBYSCORE myzset a 1", Error("ERR min or max is not a float")},
+			{"ZREVRANGE myzset 0 1 REV", Error("ERR syntax error")},
+			{"ZRANGE myzset 0 1 FOO", Error("ERR syntax error")},
+			{"ZRANGE nokey 0 -1", []any{}},
+		}},
+		{"lex", []step{
+			{"ZADD myzset 0 a 0 b 0 c 0 d 0 e 0 f 0 g", int64(7)},
+			{"ZRANGEBYLEX myzset - [c", []any{"a", "b", "c"}},
+			{"ZRANGEBYLEX myzset - (c", []any{"a", "b"}},
+			{"ZRANGEBYLEX myzset [aaa (g", []any{"b", "c", "d", "e", "f"}},
+			{"ZRANGEBYLEX myzset - + LIMIT 2 3", []any{"c", "d", "e"}},
+			{"ZREVRANGEBYLEX myzset [c -", []any{"c", "b", "a"}},
+			{"ZREVRANGEBYLEX myzset (g [aaa", []any{"f", "e", "d", "c", "b"}},
+			{"ZRANGE myzset [f + BYLEX", []any{"f", "g"}},
+			{"ZRANGE myzset + [f BYLEX REV LIMIT 0 1", []any{"g"}},
+			{"ZLEXCOUNT myzset - +", int64(7)},
+			{"ZLEXCOUNT myzset [b [f", int64(5)},
+			{"ZRANGEBYLEX myzset a +", Error("ERR min or max not valid string range item")},
+			{"ZRANGE myzset - + BYLEX WITHSCORES", Error("ERR syntax error, WITHSCORES not supported in combination with BYLEX")},
+			{"ZLEXCOUNT nokey - +", int64(0)},
+		}},
+		{"zcount", []step{
+			{"ZADD myzset 1 one 2 two 3 three", int64(3)},
+			{"ZCOUNT myzset -inf +inf", int64(3)},
+			{"ZCOUNT myzset (1 3", int64(2)},
+			{"ZCOUNT myzset (1 (3", int64(1)},
+			{"ZCOUNT myzset 3 1", int64(0)},
+			{"ZCOUNT myzset x 1", Error("ERR min or max is not a float")},
+			{"ZCARD myzset", int64(3)},
+		}},
+		{"zpop", []step{
+			{"ZADD myzset 1 one 2 two 3 three", int64(3)},
+			{"ZPOPMIN myzset", []any{"one", "1"}},
+			{"ZPOPMAX myzset", []any{"three", "3"}},
+			{"ZADD myzset 1 one 3 three", int64(2)},
+			{"ZPOPMIN myzset 2", []any{"one", "1", "two", "2"}},
+			{"ZPOPMAX myzset 5", []any{"three", "3"}},
+			{"ZPOPMAX myzset", []any{}},
+			{"EXISTS myzset", int64(0)},
+			{"ZPOPMIN myzset -1", Error("ERR value is out of range, must be positive")},
+		}},
+		{"zremrangebyrank", []step{
+			{"ZADD myzset 1 one 2 two 3 three", int64(3)},
+			{"ZREMRANGEBYRANK myzset 0 1", int64(2)},
+			{"ZRANGE myzset 0 -1 WITHSCORES", []any{"three", "3"}},
+			{"ZADD myzset 1 one 2 two 4 four", int64(3)},
+			{"ZREMRANGEBYRANK myzset -2 -1", int64(2)},
+			{"ZRANGE myzset 0 -1", []any{"one", "two"}},
+			{"ZREMRANGEBYRANK myzset 5 10", int64(0)},
+			{"ZREMRANGEBYRANK myzset 0 -1", int64(2)},
+			{"EXISTS myzset", int64(0)},
+		}},
+		{"generic", []step{
+			{"PING", SimpleString("PONG")},
+			{"PING hello", "hello"},
+			{"ECHO hello", "hello"},
+			{"SELECT 0", SimpleString("OK")},
+			{"SELECT 1", Error("ERR DB index is out of range")},
+			{"CLIENT SETNAME test", SimpleString("OK")},
+			{"ZADD a 1 x", int64(1)},
+			{"ZADD b 1 x", int64(1)},
+			{"EXISTS a b c", int64(2)},
+			{"DEL a c", int64(1)},
+			{"FLUSHALL", SimpleString("OK")},
+			{"EXISTS b", int64(0)},
+			{"GET a", Error("ERR unknown command 'GET'")},
+			{"zscore a", Error("ERR wrong number of arguments for 'zscore' command")},
+			{"ZADD myzset NX", Error("ERR wrong number of arguments for 'zadd' command")},
+			{"ZADD myzset NX XX", Error("ERR syntax error")},
+		}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			db := NewDB()
+			for _, s := range c.steps {
+				assert.Equal(t, s.want, db.Do(strings.Fields(s.cmd)...), s.cmd)
+			}
+		})
+	}
+	assert.Equal(t, Error("ERR empty command"), NewDB().Do())
+}
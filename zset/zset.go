@@ -0,0 +1,254 @@
+// Package zset implements Redis sorted sets on top of an orderstat.Tree.
+//
+// A Set pairs a Tree ordered by (score, member) with a map from member to
+// score, which is the same structure Redis builds from a skip list and a hash
+// table. Ranks are computed from the Tree's subtree counts, so rank queries,
+// range counts and range offsets take O(log n) time.
+//
+// DB layers the Redis command syntax over a collection of named Sets. It
+// implements ZADD, ZINCRBY, ZREM, ZSCORE, ZRANK, ZREVRANK, ZCARD, ZCOUNT,
+// ZLEXCOUNT, ZRANGE and its legacy BYSCORE, BYLEX and REV variants, ZPOPMIN,
+// ZPOPMAX and ZREMRANGEBYRANK, along with the handful of generic commands
+// clients issue when they connect.
+package zset
+
+import (
+	"github.com/ajwerner/orderstat"
+)
+
+// Entry is a member of a Set along with its score.
+type Entry struct {
+	Member string
+	Score  float64
+}
+
+// ScoreBound is one end of a range of scores.
+type ScoreBound struct {
+	Score     float64
+	Exclusive bool
+}
+
+// LexBound is one end of a range of members.
+type LexBound struct {
+	Member    string
+	Exclusive bool
+	// Infinite is -1 if the bound is below every member, as is "-" in the
+	// Redis syntax, 1 if it is above every member, as is "+", and 0
+	// otherwise.
+	Infinite int
+}
+
+// Set is a Redis sorted set. Members are ordered by ascending score and
+// members with equal scores are ordered lexicographically.
+//
+// Set is not safe for concurrent use.
+type Set struct {
+	tree   *orderstat.Tree
+	scores map[string]float64
+}
+
+// New creates a new, empty Set.
+func New() *Set {
+	return &Set{
+		tree:   orderstat.NewTree(),
+		scores: make(map[string]float64),
+	}
+}
+
+// Len returns the number of members in the set.
+func (s *Set) Len() int {
+	return s.tree.Len()
+}
+
+// Add sets the score of member, adding it if it is not yet present. It
+// returns true if the member was added rather than updated.
+func (s *Set) Add(member string, score float64) (added bool) {
+	prev, ok := s.scores[member]
+	if ok {
+		if prev == score {
+			return false
+		}
+		s.tree.Delete(entry{score: prev, member: member})
+	}
+	s.scores[member] = score
+	s.tree.ReplaceOrInsert(entry{score: score, member: member})
+	return !ok
+}
+
+// Remove removes member from the set. It returns false if member was not
+// present.
+func (s *Set) Remove(member string) bool {
+	score, ok := s.scores[member]
+	if !ok {
+		return false
+	}
+	delete(s.scores, member)
+	s.tree.Delete(entry{score: score, member: member})
+	return true
+}
+
+// Score returns the score of member. The second return value is false if
+// member is not present.
+func (s *Set) Score(member string) (float64, bool) {
+	score, ok := s.scores[member]
+	return score, ok
+}
+
+// Rank returns the number of members which precede member, or -1 if member is
+// not present.
+func (s *Set) Rank(member string) int {
+	score, ok := s.scores[member]
+	if !ok {
+		return -1
+	}
+	return s.tree.Rank(entry{score: score, member: member})
+}
+
+// ScoreRange returns the ranks [lo, hi) of the members whose scores lie
+// between lower and upper.
+func (s *Set) ScoreRange(lower, upper ScoreBound) (lo, hi int) {
+	lo = s.countBelow(lower.lower())
+	hi = s.countBelow(upper.upper())
+	return lo, max(lo, hi)
+}
+
+// LexRange returns the ranks [lo, hi) of the members which lie between lower
+// and upper. Like the Redis commands built on it, LexRange assumes that every
+// member has the same score; otherwise the result is unspecified.
+func (s *Set) LexRange(lower, upper LexBound) (lo, hi int) {
+	first := s.tree.Min()
+	if first == nil {
+		return 0, 0
+	}
+	score := first.(entry).score
+	lo = s.countBelow(lower.lower(score))
+	hi = s.countBelow(upper.upper(score))
+	return lo, max(lo, hi)
+}
+
+// countBelow returns the number of entries which are less than bound.
+func (s *Set) countBelow(bound entry) int {
+	if _, rank := s.tree.Ceiling(bound); rank >= 0 {
+		return rank
+	}
+	return s.tree.Len()
+}
+
+// Range returns the members with ranks in [lo, hi) in ascending order, or in
+// descending order if rev is true.
+func (s *Set) Range(lo, hi int, rev bool) []Entry {
+	lo, hi = max(lo, 0), min(hi, s.Len())
+	if lo >= hi {
+		return nil
+	}
+	entries := make([]Entry, 0, hi-lo)
+	opts := orderstat.IterOptions{
+		Lower: orderstat.InclusiveBound(s.tree.Select(lo)),
+		Upper: orderstat.InclusiveBound(s.tree.Select(hi - 1)),
+	}
+	if rev {
+		opts.Direction = orderstat.Descending
+	}
+	s.tree.Iterate(opts, func(item orderstat.Item) bool {
+		e := item.(entry)
+		entries = append(entries, Entry{Member: e.member, Score: e.score})
+		return true
+	})
+	return entries
+}
+
+// RemoveRange removes the members with ranks in [lo, hi) and returns the
+// number of members removed.
+func (s *Set) RemoveRange(lo, hi int) int {
+	removed := s.Range(lo, hi, false)
+	for _, e := range removed {
+		s.Remove(e.Member)
+	}
+	return len(removed)
+}
+
+// PopMin removes and returns up to n members with the lowest scores, in
+// ascending order.
+func (s *Set) PopMin(n int) []Entry {
+	popped := s.Range(0, n, false)
+	for range popped {
+		e := s.tree.DeleteMin().(entry)
+		delete(s.scores, e.member)
+	}
+	return popped
+}
+
+// PopMax removes and returns up to n members with the highest scores, in
+// descending order.
+func (s *Set) PopMax(n int) []Entry {
+	popped := s.Range(s.Len()-n, s.Len(), true)
+	for range popped {
+		e := s.tree.DeleteMax().(entry)
+		delete(s.scores, e.member)
+	}
+	return popped
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// entry
+////////////////////////////////////////////////////////////////////////////////
+
+// entry is the item stored in the tree. Entries used as range bounds set edge
+// so that they sort before or after every member with the same score.
+type entry struct {
+	score  float64
+	member string
+	edge   int8
+}
+
+// Less orders entries by score, then by edge and then by member.
+func (e entry) Less(other orderstat.Item) bool {
+	o := other.(entry)
+	switch {
+	case e.score != o.score:
+		return e.score < o.score
+	case e.edge != o.edge:
+		return e.edge < o.edge
+	default:
+		return e.member < o.member
+	}
+}
+
+// lower returns an entry which sorts before every member in the range above b.
+func (b ScoreBound) lower() entry {
+	if b.Exclusive {
+		return entry{score: b.Score, edge: 1}
+	}
+	return entry{score: b.Score, edge: -1}
+}
+
+// upper returns an entry which sorts after every member in the range below b.
+func (b ScoreBound) upper() entry {
+	if b.Exclusive {
+		return entry{score: b.Score, edge: -1}
+	}
+	return entry{score: b.Score, edge: 1}
+}
+
+func (b LexBound) lower(score float64) entry {
+	switch {
+	case b.Infinite != 0:
+		return entry{score: score, edge: int8(b.Infinite)}
+	case b.Exclusive:
+		// The smallest string greater than b.Member.
+		return entry{score: score, member: b.Member + "\x00"}
+	default:
+		return entry{score: score, member: b.Member}
+	}
+}
+
+func (b LexBound) upper(score float64) entry {
+	switch {
+	case b.Infinite != 0:
+		return entry{score: score, edge: int8(b.Infinite)}
+	case b.Exclusive:
+		return entry{score: score, member: b.Member}
+	default:
+		return entry{score: score, member: b.Member + "\x00"}
+	}
+}
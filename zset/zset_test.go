@@ -0,0 +1,130 @@
+package zset
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sorted returns the members of m in set order.
+func sorted(m map[string]float64) []Entry {
+	entries := make([]Entry, 0, len(m))
+	for member, score := range m {
+		entries = append(entries, Entry{member, score})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Score != b.Score {
+			return a.Score < b.Score
+		}
+		return a.Member < b.Member
+	})
+	return entries
+}
+
+func TestSet(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	s := New()
+	m := map[string]float64{}
+	for i := 0; i < 5000; i++ {
+		member := fmt.Sprintf("m%02d", rng.Intn(100))
+		switch rng.Intn(5) {
+		case 0:
+			_, ok := m[member]
+			assert.Equal(t, ok, s.Remove(member))
+			delete(m, member)
+		case 1:
+			want := sorted(m)
+			n := rng.Intn(3)
+			if rng.Intn(2) == 0 {
+				assert.Equal(t, want[:min(n, len(want))], nonNil(s.PopMin(n)))
+				for _, e := range want[:min(n, len(want))] {
+					delete(m, e.Member)
+				}
+			} else {
+				var popped []Entry
+				for i := len(want) - 1; i >= max(0, len(want)-n); i-- {
+					popped = append(popped, want[i])
+					delete(m, want[i].Member)
+				}
+				assert.Equal(t, nonNil(popped), nonNil(s.PopMax(n)))
+			}
+		default:
+			score := float64(rng.Intn(10))
+			_, ok := m[member]
+			assert.Equal(t, !ok, s.Add(member, score))
+			m[member] = score
+		}
+		require.Equal(t, len(m), s.Len())
+	}
+	want := sorted(m)
+	assert.Equal(t, want, s.Range(0, s.Len(), false))
+	for i, e := range want {
+		assert.Equal(t, i, s.Rank(e.Member))
+		score, ok := s.Score(e.Member)
+		assert.True(t, ok)
+		assert.Equal(t, e.Score, score)
+	}
+	for lower := -1.0; lower <= 10; lower += 0.5 {
+		for upper := lower - 1; upper <= 11; upper += 0.5 {
+			for _, excl := range [][2]bool{{false, false}, {true, false}, {false, true}, {true, true}} {
+				var n int
+				for _, e := range want {
+					aboveLower := e.Score > lower || (!excl[0] && e.Score == lower)
+					belowUpper := e.Score < upper || (!excl[1] && e.Score == upper)
+					if aboveLower && belowUpper {
+						n++
+					}
+				}
+				lo, hi := s.ScoreRange(ScoreBound{lower, excl[0]}, ScoreBound{upper, excl[1]})
+				assert.Equal(t, n, hi-lo, "%v %v %v", lower, upper, excl)
+			}
+		}
+	}
+}
+
+func nonNil(entries []Entry) []Entry {
+	if entries == nil {
+		return []Entry{}
+	}
+	return entries
+}
+
+func TestSetLexRange(t *testing.T) {
+	s := New()
+	for _, m := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		s.Add(m, 0)
+	}
+	for _, c := range []struct {
+		lower, upper LexBound
+		want         []string
+	}{
+		{LexBound{Infinite: -1}, LexBound{Member: "c"}, []string{"a", "b", "c"}},
+		{LexBound{Infinite: -1}, LexBound{Member: "c", Exclusive: true}, []string{"a", "b"}},
+		{LexBound{Member: "aaa"}, LexBound{Member: "g", Exclusive: true}, []string{"b", "c", "d", "e", "f"}},
+		{LexBound{Member: "e", Exclusive: true}, LexBound{Infinite: 1}, []string{"f", "g"}},
+		{LexBound{Member: "e"}, LexBound{Member: "b"}, nil},
+		{LexBound{Infinite: 1}, LexBound{Infinite: -1}, nil},
+	} {
+		lo, hi := s.LexRange(c.lower, c.upper)
+		var got []string
+		for _, e := range s.Range(lo, hi, false) {
+			got = append(got, e.Member)
+		}
+		assert.Equal(t, c.want, got, "%v %v", c.lower, c.upper)
+	}
+	s.Add("inf", math.Inf(1))
+	lo, hi := s.ScoreRange(ScoreBound{Score: math.Inf(-1)}, ScoreBound{Score: math.Inf(1)})
+	assert.Equal(t, 8, hi-lo)
+	lo, hi = s.ScoreRange(ScoreBound{Score: 0, Exclusive: true}, ScoreBound{Score: math.Inf(1)})
+	assert.Equal(t, []Entry{{"inf", math.Inf(1)}}, s.Range(lo, hi, false))
+	assert.Equal(t, 1, s.RemoveRange(7, 100))
+	assert.Equal(t, 2, s.RemoveRange(0, 2))
+	assert.Equal(t, 0, s.Rank("c"))
+	assert.Nil(t, New().Range(0, 1, false))
+}